@@ -0,0 +1,244 @@
+package clockwork
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Balance account balance. The amount is held exactly in minor units of the
+// currency, e.g. 287.58 GBP is an Amount of 28758.
+type Balance struct {
+	// Amount in minor units of Currency
+	Amount int64
+	// Currency ISO 4217 currency code e.g. GBP
+	Currency string
+	// AccountType type of account e.g. PAYG, empty if not returned by the API
+	AccountType string
+	// Fields any other 'Key: value' fields returned by the API
+	Fields map[string]string
+}
+
+// currencyExponents number of minor unit digits for ISO 4217 currencies which
+// do not use two.
+var currencyExponents = map[string]int{
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0,
+	"KRW": 0, "PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0,
+	"XOF": 0, "XPF": 0,
+}
+
+// currencyExponent returns the number of minor unit digits for currency
+func currencyExponent(currency string) int {
+	if e, ok := currencyExponents[currency]; ok {
+		return e
+	}
+	return 2
+}
+
+// Exponent returns the number of minor unit digits of the balance currency,
+// i.e. 2 for GBP and 0 for JPY.
+func (b Balance) Exponent() int {
+	return currencyExponent(b.Currency)
+}
+
+// Decimal returns the amount in major units as an exact decimal string e.g.
+// "287.58"
+func (b Balance) Decimal() string {
+	s := strconv.FormatInt(b.Amount, 10)
+	sign := ""
+	if b.Amount < 0 {
+		sign, s = "-", s[1:]
+	}
+	e := b.Exponent()
+	if e == 0 {
+		return sign + s
+	}
+	if len(s) <= e {
+		s = strings.Repeat("0", e-len(s)+1) + s
+	}
+	return sign + s[:len(s)-e] + "." + s[len(s)-e:]
+}
+
+// Float64 returns the amount in major units as a float64. The conversion may
+// lose precision, use Amount or Decimal where exactness matters.
+func (b Balance) Float64() float64 {
+	f, _ := strconv.ParseFloat(b.Decimal(), 64)
+	return f
+}
+
+// String returns the balance as a string e.g. "287.58 GBP"
+func (b Balance) String() string {
+	return b.Decimal() + " " + b.Currency
+}
+
+// DoBalanceRequestHelper helper to make a HTTP Get request
+func DoBalanceRequestHelper(d Doer, key string, url string) (Balance, error) {
+	return doBalanceRequest(context.Background(), d, key, url)
+}
+
+// doBalanceRequest makes a HTTP Get request, cancelled when ctx is done
+func doBalanceRequest(ctx context.Context, d Doer, key string, url string) (Balance, error) {
+	req, err := http.NewRequest("GET", url+"?key="+key, nil)
+	if err != nil {
+		return Balance{}, err
+	}
+	req = req.WithContext(ctx)
+
+	// identify this client to the clockwork SMS API
+	userAgent := "Clockwork Go wrapper/" + Version()
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	resp, err := d.Do(req)
+	if err != nil {
+		return Balance{}, err
+	}
+
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+
+	if resp.StatusCode != 200 {
+		return Balance{}, ErrStatusCode
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Balance{}, err
+	}
+
+	return parseBalanceResponseBody(string(body))
+}
+
+// DoCreditRequestHelper helper to make a HTTP Get request
+func DoCreditRequestHelper(d Doer, key string, url string) (credit float64, code string, err error) {
+	return creditFromBalance(DoBalanceRequestHelper(d, key, url))
+}
+
+// creditFromBalance returns a balance as the float64 amount and currency code
+// returned by Credit. An amount with more decimal places than its currency
+// cannot be held exactly by Balance, but Credit has always accepted it, so it
+// falls back to parsing the amount as a float64.
+func creditFromBalance(b Balance, err error) (float64, string, error) {
+	if pe, ok := err.(*ParseError); ok {
+		if m := matchBalance.FindStringSubmatch(pe.Line); m != nil {
+			if f, ferr := strconv.ParseFloat(m[1], 64); ferr == nil {
+				return f, m[2], nil
+			}
+		}
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	return b.Float64(), b.Currency, nil
+}
+
+// parseBalanceResponseBody parse the plain text response body from a clockwork
+// /balance HTTP call.
+func parseBalanceResponseBody(body string) (Balance, error) {
+	var b Balance
+	var found bool
+	lines := responseLines(body)
+	if len(lines) == 0 {
+		return b, &ParseError{Reason: "empty response body"}
+	}
+	for _, line := range lines {
+		// error response is in the following plain-text format:
+		//
+		//	Error 58: Invalid API Key
+		//
+		if m := matchError.FindStringSubmatch(line); m != nil {
+			return Balance{}, apiError(line, m[1])
+		}
+		// valid response body is in the following plain-text format:
+		//
+		//	Balance: 287.58 (GBP)
+		//
+		// optionally followed by other 'Key: value' lines
+		if m := matchBalance.FindStringSubmatch(line); m != nil && !found {
+			amount, err := parseMinorUnits(m[1], currencyExponent(m[2]))
+			if err != nil {
+				return Balance{}, &ParseError{Line: line, Reason: err.Error()}
+			}
+			b.Amount, b.Currency, found = amount, m[2], true
+			continue
+		}
+		fields, ok := parseFields(line)
+		if !ok {
+			return Balance{}, &ParseError{Line: line, Reason: "expected 'Balance: <amount> (<currency>)'"}
+		}
+		for k, v := range fields {
+			if k == "AccountType" {
+				b.AccountType = v
+				continue
+			}
+			if b.Fields == nil {
+				b.Fields = make(map[string]string)
+			}
+			b.Fields[k] = v
+		}
+	}
+	if !found {
+		return Balance{}, &ParseError{Line: lines[0], Reason: "expected 'Balance: <amount> (<currency>)'"}
+	}
+	return b, nil
+}
+
+// roundedBalance returns the balance from a *ParseError for an amount with
+// more decimal places than its currency, rounded down to the currency's minor
+// unit. It reports false for any other error.
+func roundedBalance(err error) (Balance, bool) {
+	pe, ok := err.(*ParseError)
+	if !ok {
+		return Balance{}, false
+	}
+	m := matchBalance.FindStringSubmatch(pe.Line)
+	if m == nil {
+		return Balance{}, false
+	}
+	amount, exponent := m[1], currencyExponent(m[2])
+	var dropped string
+	if i := strings.Index(amount, "."); i >= 0 && len(amount)-i-1 > exponent {
+		amount, dropped = amount[:i+1+exponent], amount[i+1+exponent:]
+	}
+	n, perr := parseMinorUnits(amount, exponent)
+	if perr != nil {
+		return Balance{}, false
+	}
+	if strings.HasPrefix(amount, "-") && strings.Trim(dropped, "0") != "" {
+		n--
+	}
+	return Balance{Amount: n, Currency: m[2]}, true
+}
+
+// parseMinorUnits converts a decimal string e.g. "-94.2" to an integer number
+// of minor units e.g. -9420 for exponent 2. Digits beyond exponent must be
+// zero, so no precision is lost.
+func parseMinorUnits(s string, exponent int) (int64, error) {
+	whole, frac := s, ""
+	if i := strings.Index(s, "."); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	extra := ""
+	if len(frac) > exponent {
+		frac, extra = frac[:exponent], frac[exponent:]
+	}
+	if strings.Trim(extra, "0") != "" {
+		return 0, fmt.Errorf("amount %s has more than %d decimal places", s, exponent)
+	}
+	frac += strings.Repeat("0", exponent-len(frac))
+	neg := strings.HasPrefix(whole, "-")
+	whole = strings.TrimLeft(whole, "+-")
+	n, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if neg {
+		n = -n
+	}
+	return n, nil
+}
@@ -0,0 +1,117 @@
+package clockwork
+
+import (
+	"sync"
+	"time"
+)
+
+// Budget caps the number of message parts sent per UTC day and per UTC
+// calendar month. Each recipient of each part counts once, so a two part
+// message to three numbers uses six. The counters are held in memory and are
+// safe for concurrent use.
+//
+// Set it on Clockwork so a runaway loop stops with ErrBudgetExceeded rather
+// than draining the account:
+//
+//	cw.Budget = &clockwork.Budget{Daily: 1000, Monthly: 20000}
+type Budget struct {
+	// Daily maximum parts per UTC day, 0 for no limit
+	Daily int
+	// Monthly maximum parts per UTC calendar month, 0 for no limit
+	Monthly int
+
+	mu        sync.Mutex
+	day       string
+	month     string
+	dayUsed   int
+	monthUsed int
+}
+
+// Used returns the parts used so far today and this month
+func (b *Budget) Used() (daily, monthly int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.roll(time.Now().UTC())
+	return b.dayUsed, b.monthUsed
+}
+
+// Remaining returns the parts left today and this month, -1 if there is no
+// limit.
+func (b *Budget) Remaining() (daily, monthly int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.roll(time.Now().UTC())
+	daily, monthly = -1, -1
+	if b.Daily > 0 {
+		daily = max0(b.Daily - b.dayUsed)
+	}
+	if b.Monthly > 0 {
+		monthly = max0(b.Monthly - b.monthUsed)
+	}
+	return daily, monthly
+}
+
+// budgetReservation parts taken from a budget by reserve
+type budgetReservation struct {
+	day   string
+	month string
+	parts int
+}
+
+// reserve takes parts from the budget, returning ErrBudgetExceeded if either
+// limit would be exceeded. A nil budget has no limits.
+func (b *Budget) reserve(parts int) (budgetReservation, error) {
+	if b == nil {
+		return budgetReservation{}, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.roll(time.Now().UTC())
+	if b.Daily > 0 && b.dayUsed+parts > b.Daily {
+		return budgetReservation{}, ErrBudgetExceeded
+	}
+	if b.Monthly > 0 && b.monthUsed+parts > b.Monthly {
+		return budgetReservation{}, ErrBudgetExceeded
+	}
+	b.dayUsed += parts
+	b.monthUsed += parts
+	return budgetReservation{day: b.day, month: b.month, parts: parts}, nil
+}
+
+// refund gives back up to r.parts of a reservation which were not sent. Parts
+// reserved in a period which has since ended are not refunded.
+func (b *Budget) refund(r budgetReservation, parts int) {
+	if b == nil || parts <= 0 {
+		return
+	}
+	if parts > r.parts {
+		parts = r.parts
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.roll(time.Now().UTC())
+	if r.day == b.day {
+		b.dayUsed = max0(b.dayUsed - parts)
+	}
+	if r.month == b.month {
+		b.monthUsed = max0(b.monthUsed - parts)
+	}
+}
+
+// roll resets the counters when a new day or month starts
+func (b *Budget) roll(now time.Time) {
+	if day := now.Format("2006-01-02"); day != b.day {
+		b.day, b.dayUsed = day, 0
+	}
+	if month := now.Format("2006-01"); month != b.month {
+		b.month, b.monthUsed = month, 0
+	}
+}
+
+// max0 returns n or 0 if n is negative
+func max0(n int) int {
+	if n < 0 {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,198 @@
+package clockwork
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Sender implemented by any type which can send an SMS, such as *Clockwork
+type Sender interface {
+	SendContext(ctx context.Context, sms SMS) (SMSResponse, error)
+}
+
+// DefaultBulkWorkers number of concurrent sends a BulkSender makes if Workers
+// is not set
+const DefaultBulkWorkers = 4
+
+// BulkResult outcome of sending one SMS with a BulkSender
+type BulkResult struct {
+	// Index position of the SMS in the input, starting at 0. Results arrive
+	// out of order.
+	Index int
+	// SMS the message which was sent
+	SMS SMS
+	// Response from Send
+	Response SMSResponse
+	// Err from Send
+	Err error
+}
+
+// BulkProgress counters for a BulkSender
+type BulkProgress struct {
+	// Sent number of SMS values sent without error
+	Sent int64
+	// Failed number of SMS values which returned an error, this includes
+	// messages with some invalid numbers (ErrInvalidTo)
+	Failed int64
+	// Parts number of message parts sent, see SMS.Parts. Only numbers which
+	// were given a message ID are counted.
+	Parts int64
+}
+
+// BulkSender sends many messages with bounded concurrency, for example a
+// campaign of thousands of SMS values. Each message is sent with Sender so
+// suppression lists, budgets, rate limits etc. configured on a *Clockwork
+// apply.
+//
+//	bs := &clockwork.BulkSender{Sender: cw, Workers: 8}
+//	for r := range bs.Run(ctx, msgs) {
+//	    if r.Err != nil {
+//	        // ...
+//	    }
+//	}
+type BulkSender struct {
+	// the counters are updated atomically, so they come first to be 64-bit
+	// aligned on 32-bit platforms
+	sent   int64
+	failed int64
+	parts  int64
+
+	// Sender sends each message, usually a *Clockwork
+	Sender Sender
+	// Workers maximum number of concurrent sends, defaults to
+	// DefaultBulkWorkers
+	Workers int
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+// Run sends each SMS received on msgs and streams a BulkResult for each one
+// on the returned channel. The channel is closed once msgs is closed and every
+// message has been sent, or once ctx is done or Stop is called, in which case
+// messages still waiting on msgs are not sent. The caller must read the
+// results until the channel is closed.
+func (b *BulkSender) Run(ctx context.Context, msgs <-chan SMS) <-chan BulkResult {
+	ctx, cancel := context.WithCancel(ctx)
+	b.mu.Lock()
+	b.cancel = cancel
+	b.mu.Unlock()
+
+	workers := b.Workers
+	if workers <= 0 {
+		workers = DefaultBulkWorkers
+	}
+
+	type job struct {
+		index int
+		sms   SMS
+	}
+	jobs := make(chan job)
+	results := make(chan BulkResult, workers)
+
+	// number the messages as they arrive so results can be matched to input
+	go func() {
+		defer close(jobs)
+		for i := 0; ; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case sms, ok := <-msgs:
+				if !ok {
+					return
+				}
+				select {
+				case jobs <- job{i, sms}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				results <- b.send(ctx, j.index, j.sms)
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		cancel()
+		close(results)
+	}()
+
+	return results
+}
+
+// RunFunc is like Run but takes messages from next until it returns false
+func (b *BulkSender) RunFunc(ctx context.Context, next func() (SMS, bool)) <-chan BulkResult {
+	msgs := make(chan SMS)
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		defer close(msgs)
+		for {
+			sms, ok := next()
+			if !ok {
+				return
+			}
+			select {
+			case msgs <- sms:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	results := make(chan BulkResult)
+	go func() {
+		defer cancel()
+		defer close(results)
+		for r := range b.Run(ctx, msgs) {
+			results <- r
+		}
+	}()
+	return results
+}
+
+// Stop stops the current run, in-flight sends are cancelled
+func (b *BulkSender) Stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cancel != nil {
+		b.cancel()
+	}
+}
+
+// Progress returns the counters for every message sent so far. It may be
+// called while a run is in progress.
+func (b *BulkSender) Progress() BulkProgress {
+	return BulkProgress{
+		Sent:   atomic.LoadInt64(&b.sent),
+		Failed: atomic.LoadInt64(&b.failed),
+		Parts:  atomic.LoadInt64(&b.parts),
+	}
+}
+
+// send sends a single message and updates the counters
+func (b *BulkSender) send(ctx context.Context, index int, sms SMS) BulkResult {
+	resp, err := b.Sender.SendContext(ctx, sms)
+	if err != nil {
+		atomic.AddInt64(&b.failed, 1)
+	} else {
+		atomic.AddInt64(&b.sent, 1)
+	}
+	var sentTo int
+	for _, meta := range resp {
+		if meta["ID"] != "" {
+			sentTo++
+		}
+	}
+	atomic.AddInt64(&b.parts, int64(sms.Parts()*sentTo))
+	return BulkResult{Index: index, SMS: sms, Response: resp, Err: err}
+}
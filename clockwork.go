@@ -1,12 +1,15 @@
 package clockwork
 
 import (
-	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
-	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -57,6 +60,14 @@ const (
 	ReplaceExtraText
 )
 
+// API limits
+const (
+	// maxClientIDLength longest ClientID the API accepts
+	maxClientIDLength = 50
+	// maxRecipients most numbers a single SMS can be sent to
+	maxRecipients = 50
+)
+
 // SMS represents a single SMS message
 type SMS struct {
 	// To list of up to 50 numbers. Phone numbers must be in international number
@@ -103,11 +114,47 @@ type SMS struct {
 	// long. Truncate only works with standard text messages (MsgType=TEXT).
 	// Possible values - ErrorIfContentTooLong, ReplaceExtraText.
 	Truncate int
+	// IdempotencyKey if set and ClientID is empty, the ClientID is derived
+	// from this key, so sending the same logical message twice produces the
+	// same ClientID. It is not sent to the API.
+	IdempotencyKey string
 }
 
 // SMSResponse stores telephone numbers and there associated meta data
 type SMSResponse map[string]map[string]string
 
+// Suppressed returns the numbers which were not sent to because they are on
+// the suppression list.
+func (r SMSResponse) Suppressed() Numbers {
+	return r.flagged("Suppressed")
+}
+
+// Blocked returns the numbers which are not permitted by the destination
+// policy.
+func (r SMSResponse) Blocked() Numbers {
+	return r.flagged("Blocked")
+}
+
+// flagged returns the numbers with key set to "true" in sorted order
+func (r SMSResponse) flagged(key string) Numbers {
+	var nums Numbers
+	for num, meta := range r {
+		if meta[key] == "true" {
+			nums = append(nums, num)
+		}
+	}
+	sort.Strings(nums)
+	return nums
+}
+
+// flag sets key to "true" for each number in nums
+func (r SMSResponse) flag(key string, nums Numbers) SMSResponse {
+	for _, n := range nums {
+		r[n] = map[string]string{key: "true"}
+	}
+	return r
+}
+
 // Doer implemented by any type which can do HTTP requests
 type Doer interface {
 	Do(*http.Request) (*http.Response, error)
@@ -119,6 +166,23 @@ type Numbers []string
 // Clockwork instance
 type Clockwork struct {
 	apiKey string
+	// Client performs HTTP requests on behalf of Clockwork. If nil, a default
+	// http.Client is used.
+	Client Doer
+	// Suppression numbers on this list are removed from an SMS before it is
+	// sent, e.g. recipients who have replied STOP. Removed numbers are
+	// reported in the SMSResponse as suppressed.
+	Suppression SuppressionList
+	// Destinations if set, restricts the numbers messages may be sent to. A
+	// message with any number outside the policy is not sent.
+	Destinations *DestinationPolicy
+	// Budget if set, caps the message parts sent per day and month.
+	Budget *Budget
+	// Limiter if set, limits the rate of send and status requests.
+	Limiter *RateLimiter
+	// GenerateClientID if true, a random ClientID is set on messages which
+	// have neither a ClientID nor an IdempotencyKey.
+	GenerateClientID bool
 }
 
 // New creates a new instance of Clockwork SMS
@@ -152,17 +216,113 @@ func New(apiKey string) *Clockwork {
 //      resp["13052645330"] == true
 //      resp["meh!"] == false
 //
+// Each valid number maps to the fields the API returned for it, always "ID"
+// and any others such as "ClientID".
+//
+// Numbers on the Suppression list are never sent to. They appear in the
+// 'SMSResponse' map with the "Suppressed" key set, see SMSResponse.Suppressed.
+// If every number is suppressed no request is made.
+//
+// If any number is not permitted by the Destinations policy, the message is
+// not sent to anyone and ErrBlockedDestination is returned. The offending
+// numbers are listed by SMSResponse.Blocked.
+//
+// If the Budget would be exceeded by the estimated parts (see SMS.Parts) the
+// message is not sent and ErrBudgetExceeded is returned.
+//
+// If a Limiter is set, Send waits for it or returns ErrRateLimited.
+//
+// A ClientID is generated if the message has none and either IdempotencyKey
+// or GenerateClientID is set. Each valid number's "ClientID" field holds the
+// ClientID the message was sent with. A ClientID longer than 50 characters
+// returns ErrLongClientID.
 func (c *Clockwork) Send(sms SMS) (SMSResponse, error) {
-	return DoSendRequestHelper(c, c.apiKey, SendURL, sms)
+	return c.SendContext(context.Background(), sms)
+}
+
+// SendContext is like Send but waiting for the rate limiter and the HTTP
+// request are cancelled when ctx is done.
+func (c *Clockwork) SendContext(ctx context.Context, sms SMS) (SMSResponse, error) {
+	if sms.ClientID == "" && (sms.IdempotencyKey != "" || c.GenerateClientID) {
+		id, err := generateClientID(sms.IdempotencyKey)
+		if err != nil {
+			return nil, err
+		}
+		sms.ClientID = id
+	}
+	if len(sms.ClientID) > maxClientIDLength {
+		return nil, ErrLongClientID
+	}
+	sms, suppressed, err := suppress(c.Suppression, sms)
+	if err != nil {
+		return nil, err
+	}
+	if resp, err := checkDestinations(c.Destinations, sms.To); err != nil {
+		return resp, err
+	}
+	if len(suppressed) > 0 && len(sms.To) == 0 {
+		return make(SMSResponse).flag("Suppressed", suppressed), nil
+	}
+	parts := sms.Parts()
+	if err := c.Limiter.waitSend(ctx, len(sms.To), parts*len(sms.To)); err != nil {
+		return nil, err
+	}
+	reservation, err := c.Budget.reserve(parts * len(sms.To))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := doSendRequest(ctx, c, c.apiKey, SendURL, sms)
+	// refund only what the API definitely did not send. After a transport or
+	// parse error the message may have been sent, so it still counts.
+	switch {
+	case err == nil || err == ErrInvalidTo:
+		// numbers without a message ID were not sent to
+		c.Budget.refund(reservation, parts*(len(sms.To)-len(resp)))
+	case isAPIError(err):
+		c.Budget.refund(reservation, parts*len(sms.To))
+	}
+	if resp == nil && err != nil && len(suppressed) == 0 {
+		return nil, err
+	}
+	if resp == nil {
+		// the suppressed numbers are still reported when the request failed
+		resp = make(SMSResponse)
+	}
+	if sms.ClientID != "" {
+		for _, meta := range resp {
+			if meta["ClientID"] == "" {
+				meta["ClientID"] = sms.ClientID
+			}
+		}
+	}
+	return resp.flag("Suppressed", suppressed), err
 }
 
-// Credit check how much credit you have left on your account
+// Credit check how much credit you have left on your account. The amount is
+// a float64 approximation, use Balance for the exact amount.
 func (c *Clockwork) Credit() (credit float64, code string, err error) {
-	return DoCreditRequestHelper(c, c.apiKey, CreditURL)
+	return creditFromBalance(c.Balance())
+}
+
+// Balance check how much credit you have left on your account
+func (c *Clockwork) Balance() (Balance, error) {
+	return c.BalanceContext(context.Background())
+}
+
+// BalanceContext is like Balance but waiting for the rate limiter and the HTTP
+// request are cancelled when ctx is done.
+func (c *Clockwork) BalanceContext(ctx context.Context) (Balance, error) {
+	if err := c.Limiter.waitStatus(ctx); err != nil {
+		return Balance{}, err
+	}
+	return doBalanceRequest(ctx, c, c.apiKey, CreditURL)
 }
 
 // Do performs a HTTP request
 func (c *Clockwork) Do(req *http.Request) (*http.Response, error) {
+	if c.Client != nil {
+		return c.Client.Do(req)
+	}
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
@@ -171,8 +331,16 @@ func (c *Clockwork) Do(req *http.Request) (*http.Response, error) {
 	return resp, nil
 }
 
-// DoSendRequestHelper helper to make a HTTP Get request using 'sms' values
+// DoSendRequestHelper helper to make a HTTP Get request using 'sms' values. It
+// makes the request as given: the Clockwork policies such as Destinations are
+// only applied by Send and SendContext.
 func DoSendRequestHelper(d Doer, key string, url string, sms SMS) (SMSResponse, error) {
+	return doSendRequest(context.Background(), d, key, url, sms)
+}
+
+// doSendRequest makes a HTTP Get request using 'sms' values, cancelled when
+// ctx is done
+func doSendRequest(ctx context.Context, d Doer, key string, url string, sms SMS) (SMSResponse, error) {
 	m := smsSetOptions(sms)
 	m["Key"] = key
 	q := urlEncode(m)
@@ -181,6 +349,7 @@ func DoSendRequestHelper(d Doer, key string, url string, sms SMS) (SMSResponse,
 	if err != nil {
 		return nil, err
 	}
+	req = req.WithContext(ctx)
 
 	// identify this client to the clockwork SMS API
 	userAgent := "Clockwork Go wrapper/" + Version()
@@ -208,90 +377,49 @@ func DoSendRequestHelper(d Doer, key string, url string, sms SMS) (SMSResponse,
 	return parseSendResponseBody(string(body))
 }
 
-// DoCreditRequestHelper helper to make a HTTP Get request
-func DoCreditRequestHelper(d Doer, key string, url string) (credit float64, code string, err error) {
-
-	req, err := http.NewRequest("GET", url+"?key="+key, nil)
-	if err != nil {
-		return 0, "", err
-	}
-
-	// identify this client to the clockwork SMS API
-	userAgent := "Clockwork Go wrapper/" + Version()
-	req.Header.Set("User-Agent", userAgent)
-	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
-
-	resp, err := d.Do(req)
-	if err != nil {
-		return 0, "", err
-	}
-
-	if resp != nil {
-		defer resp.Body.Close()
-	}
-
-	if resp.StatusCode != 200 {
-		return 0, "", ErrStatusCode
-	}
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return 0, "", err
-	}
-
-	return parseCreditResponseBody(string(body))
-}
-
 // parseSendResponseBody parse the plain text response body from a clockwork
-// /send HTTP call.
+// /send HTTP call. Any line which is not in one of the formats below results
+// in a *ParseError.
 func parseSendResponseBody(body string) (SMSResponse, error) {
 	// if some of the numbers provided to SMS message contained bad numbers,
 	// flag it. So we can return the correct error to the caller.
 	var badNumbers bool
 	nums := make(SMSResponse)
-	scanner := bufio.NewScanner(strings.NewReader(body))
-	scanner.Split(bufio.ScanLines)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.Contains(line, "Error") {
-			// error response
-			if strings.Contains(line, "To:") {
-				// special case - if user specified invalid 'To' number, error
-				// response is formatted as:
-				//
-				//       To: 123 Error 10: Invalid 'To' Parameter
-				//       To: 456 Error 10: Invalid 'To' Parameter
-				//       To: 441234567890 ID: VE_439221450
-				//
-				// the first two lines are bad numbers the last line is a valid
-				// number.
-				badNumbers = true
-				continue
-			} else {
-				// all other error responses are formated as:
-				//
-				//      Error <number>: <message>
-				//
-				r := regexp.MustCompile(matchErrorNumber)
-				i, err := strconv.Atoi(r.FindString(line))
-				if err != nil {
-					return nil, err
-				}
-				return nil, errorFromCode(i)
-			}
-		} else {
-			// valid responses are formatted as:
-			//
-			//      To: <number> ID: <id>
-			//
-			extractTo := regexp.MustCompile(matchTo)
-			extractID := regexp.MustCompile(matchID)
-
-			to := strings.Split(extractTo.FindString(line), " ")[1]
-			id := strings.Split(extractID.FindString(line), " ")[1]
-
-			nums[to] = map[string]string{"ID": id}
+	lines := responseLines(body)
+	if len(lines) == 0 {
+		return nil, &ParseError{Reason: "empty response body"}
+	}
+	for _, line := range lines {
+		// special case - if user specified invalid 'To' number, error
+		// response is formatted as:
+		//
+		//       To: 123 Error 10: Invalid 'To' Parameter
+		//       To: 456 Error 10: Invalid 'To' Parameter
+		//       To: 441234567890 ID: VE_439221450
+		//
+		// the first two lines are bad numbers the last line is a valid
+		// number.
+		if matchToError.MatchString(line) {
+			badNumbers = true
+			continue
+		}
+		// all other error responses are formated as:
+		//
+		//      Error <number>: <message>
+		//
+		if m := matchError.FindStringSubmatch(line); m != nil {
+			return nil, apiError(line, m[1])
+		}
+		// valid responses are formatted as:
+		//
+		//      To: <number> ID: <id> [<key>: <value> ...]
+		//
+		// every field other than 'To' is kept, e.g. a 'ClientID' echoed back
+		to, fields, ok := parseSendLine(line)
+		if !ok {
+			return nil, &ParseError{Line: line, Reason: "expected 'To: <number> ID: <id>'"}
 		}
+		nums[to] = fields
 	}
 
 	if badNumbers {
@@ -304,36 +432,81 @@ func parseSendResponseBody(body string) (SMSResponse, error) {
 	return nums, nil
 }
 
-// parseCreditResponseBody parse the plain text response body from a clockwork
-// /credit HTTP call.
-func parseCreditResponseBody(body string) (float64, string, error) {
-	if strings.Contains(body, "Error") {
-		// error response is in the following plain-text format:
-		//
-		//	Error 58: Invalid API Key
-		//
-		r := regexp.MustCompile(matchErrorNumber)
-		n := r.FindString(body)
-		i, err := strconv.Atoi(n)
-		if err != nil {
-			return 0, "", err
+// responseLines splits a plain text response body into lines. Each line is
+// trimmed and has runs of whitespace (tabs, CRs) collapsed to a single space.
+// Blank lines are dropped.
+func responseLines(body string) []string {
+	var lines []string
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line != "" {
+			lines = append(lines, line)
 		}
-		return 0, "", errorFromCode(i)
-	}
-	// valid response body is in the following plain-text format:
-	//
-	//	Balance: 287.58 (GBP)
-	//
-	// extract amount 287.58 and currency code GBP
-	extractAmount := regexp.MustCompile(matchCurrency)
-	extractCurrencyCode := regexp.MustCompile(matchCurrencyCode)
-	a := extractAmount.FindString(body)
-	c := extractCurrencyCode.FindString(body)
-	s, err := strconv.ParseFloat(a, 64)
+	}
+	return lines
+}
+
+// parseSendLine parses a successful send response line into its number and
+// other fields. To and ID come first and never contain spaces. A ClientID is
+// our own text echoed back, so it runs to the end of the line and may itself
+// look like 'Key: value'. Any other fields between ID and ClientID are kept.
+func parseSendLine(line string) (string, map[string]string, bool) {
+	m := matchSendLine.FindStringSubmatch(line)
+	if m == nil {
+		return "", nil, false
+	}
+	fields := map[string]string{"ID": m[2]}
+	rest := m[3]
+	if loc := matchClientIDKey.FindStringIndex(rest); loc != nil {
+		fields["ClientID"] = strings.TrimSpace(rest[loc[1]:])
+		rest = rest[:loc[0]]
+	}
+	if rest == "" {
+		return m[1], fields, true
+	}
+	extra, ok := parseFields(rest)
+	if !ok {
+		return "", nil, false
+	}
+	for k, v := range extra {
+		if _, dup := fields[k]; dup || k == "To" {
+			return "", nil, false
+		}
+		fields[k] = v
+	}
+	return m[1], fields, true
+}
+
+// parseFields splits a line of 'Key: value' pairs into a map. A value runs up
+// to the next key, so it may contain spaces. It reports false if the line does
+// not start with a key or a key is repeated.
+func parseFields(line string) (map[string]string, bool) {
+	idx := matchFieldKey.FindAllStringSubmatchIndex(line, -1)
+	if len(idx) == 0 || idx[0][0] != 0 {
+		return nil, false
+	}
+	fields := make(map[string]string, len(idx))
+	for i, m := range idx {
+		key := line[m[2]:m[3]]
+		end := len(line)
+		if i+1 < len(idx) {
+			end = idx[i+1][0]
+		}
+		if _, dup := fields[key]; dup {
+			return nil, false
+		}
+		fields[key] = strings.TrimSpace(line[m[1]:end])
+	}
+	return fields, true
+}
+
+// apiError converts the error code found on line to a Clockwork API error
+func apiError(line string, code string) error {
+	i, err := strconv.Atoi(code)
 	if err != nil {
-		return 0, "", err
+		return &ParseError{Line: line, Reason: "bad error code"}
 	}
-	return s, c, nil
+	return errorFromCode(i)
 }
 
 // urlEncode encodes key/value pairs in a map as a HTTP Get query string. e.g.
@@ -385,6 +558,41 @@ func smsSetOptions(sms SMS) map[string]string {
 	return vals
 }
 
+// generateClientID returns a ClientID derived from key, or a random one if key
+// is empty. Either way it is 32 hex characters, within the API's limit.
+func generateClientID(key string) (string, error) {
+	if key == "" {
+		return randomHex(16)
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+// randomHex returns n random bytes hex encoded
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// normaliseNumber strips formatting from a telephone number so different
+// spellings of the same number compare equal, e.g. "+44 (0)1234-567 890",
+// "0044 1234 567890" and "441234567890".
+func normaliseNumber(n string) string {
+	n = strings.Replace(n, "(0)", "", -1)
+	n = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '-', '(', ')', '.':
+			return -1
+		}
+		return r
+	}, n)
+	n = strings.TrimPrefix(n, "+")
+	return strings.TrimPrefix(n, "00")
+}
+
 // formatTime formats a time instance in the form yyyyMMddHHmm e.g. 201110201530
 func formatTime(t time.Time) string {
 	return fmt.Sprintf("%04d%02d%02d%02d%02d", t.Year(), t.Month(),
@@ -0,0 +1,83 @@
+package clockwork_test
+
+import (
+	"testing"
+
+	"github.com/umahmood/clockwork"
+)
+
+// Balance mock balance
+func (m *mockClockwork) Balance() (clockwork.Balance, error) {
+	return clockwork.DoBalanceRequestHelper(m, m.apiKey, "https://test.com/credit")
+}
+
+// TestBalance
+func TestBalance(t *testing.T) {
+	testCases := []struct {
+		body       string
+		wantAmount int64
+		wantString string
+	}{
+		{"Balance: 287.58 (GBP)", 28758, "287.58 GBP"},
+		{"Balance: 0.05 (EUR)", 5, "0.05 EUR"},
+		{"Balance: -94.2 (GBP)", -9420, "-94.20 GBP"},
+		{"Balance: -0.07 (USD)", -7, "-0.07 USD"},
+		{"Balance: 12 (GBP)", 1200, "12.00 GBP"},
+		{"Balance: 1500 (JPY)", 1500, "1500 JPY"},
+		{"Balance: 1.250 (KWD)", 1250, "1.250 KWD"},
+		{"Balance: 3.100 (GBP)", 310, "3.10 GBP"},
+	}
+
+	for _, tc := range testCases {
+		got, err := mockBody(tc.body).Balance()
+		if err != nil {
+			t.Errorf("Fail: %s - err got %v want nil", tc.body, err)
+			continue
+		}
+		if got.Amount != tc.wantAmount {
+			t.Errorf("Fail: %s - amount got %d want %d", tc.body, got.Amount, tc.wantAmount)
+		}
+		if got.String() != tc.wantString {
+			t.Errorf("Fail: %s - string got %s want %s", tc.body, got.String(), tc.wantString)
+		}
+	}
+}
+
+// TestBalanceFields
+func TestBalanceFields(t *testing.T) {
+	body := "Balance: 287.58 (GBP)\nAccountType: PAYG\nCreditLimit: 100"
+
+	got, err := mockBody(body).Balance()
+	if err != nil {
+		t.Fatalf("Fail: err - got %v want nil", err)
+	}
+
+	if got.AccountType != "PAYG" {
+		t.Errorf("Fail: account type - got %s want PAYG", got.AccountType)
+	}
+
+	if got.Fields["CreditLimit"] != "100" {
+		t.Errorf("Fail: fields - got %v want CreditLimit 100", got.Fields)
+	}
+}
+
+// TestBalanceTooPrecise
+func TestBalanceTooPrecise(t *testing.T) {
+	_, err := mockBody("Balance: 287.585 (GBP)").Balance()
+	if _, ok := err.(*clockwork.ParseError); !ok {
+		t.Errorf("Fail: err - got %v want *ParseError", err)
+	}
+
+	// Credit still accepts it, as a float64
+	credit, code, err := mockBody("Balance: 287.585 (GBP)").Credit()
+	if err != nil {
+		t.Fatalf("Fail: Credit err - got %v want nil", err)
+	}
+	if credit != 287.585 || code != "GBP" {
+		t.Errorf("Fail: Credit - got %v %s want 287.585 GBP", credit, code)
+	}
+
+	if _, _, err := mockBody("Balance: lots").Credit(); err == nil {
+		t.Errorf("Fail: Credit malformed err - got nil want an error")
+	}
+}
@@ -0,0 +1,120 @@
+package clockwork_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/umahmood/clockwork"
+)
+
+// TestParts
+func TestParts(t *testing.T) {
+	testCases := []struct {
+		name string
+		sms  clockwork.SMS
+		want int
+	}{
+		{"empty", clockwork.SMS{}, 1},
+		{"160 gsm", clockwork.SMS{Content: strings.Repeat("a", 160)}, 1},
+		{"161 gsm", clockwork.SMS{Content: strings.Repeat("a", 161)}, 2},
+		{"306 gsm", clockwork.SMS{Content: strings.Repeat("a", 306)}, 2},
+		{"307 gsm", clockwork.SMS{Content: strings.Repeat("a", 307)}, 3},
+		{"80 extended gsm", clockwork.SMS{Content: strings.Repeat("€", 80)}, 1},
+		{"81 extended gsm", clockwork.SMS{Content: strings.Repeat("€", 81)}, 2},
+		{"70 ucs2", clockwork.SMS{MsgType: clockwork.UCS2, Content: strings.Repeat("ж", 70)}, 1},
+		{"71 ucs2", clockwork.SMS{MsgType: clockwork.UCS2, Content: strings.Repeat("ж", 71)}, 2},
+		{"36 ucs2 surrogate pairs", clockwork.SMS{MsgType: clockwork.UCS2, Content: strings.Repeat("😀", 36)}, 2},
+		{"truncated", clockwork.SMS{Content: strings.Repeat("a", 500), Concat: clockwork.TwoParts, Truncate: clockwork.ReplaceExtraText}, 2},
+	}
+
+	for _, tc := range testCases {
+		if got := tc.sms.Parts(); got != tc.want {
+			t.Errorf("Fail: %s - got %d want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+// TestSendBudget
+func TestSendBudget(t *testing.T) {
+	cw := clockwork.New(testAPIKey)
+	cw.Budget = &clockwork.Budget{Daily: 5}
+	cw.Client = doerFunc(func(req *http.Request) (*http.Response, error) {
+		body := "To: 441234567890 ID: VE_1\nTo: 123 Error 10: Invalid 'To' Parameter"
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+		}, nil
+	})
+
+	msg := clockwork.SMS{
+		To:      clockwork.Numbers{"441234567890", "123"},
+		Content: strings.Repeat("a", 200), // 2 parts
+	}
+
+	// 4 parts reserved, the invalid number's 2 are refunded
+	if _, err := cw.Send(msg); err != clockwork.ErrInvalidTo {
+		t.Fatalf("Fail: err - got %v want %v", err, clockwork.ErrInvalidTo)
+	}
+	if daily, _ := cw.Budget.Used(); daily != 2 {
+		t.Errorf("Fail: used - got %d want 2", daily)
+	}
+
+	// another 4 parts would exceed the daily budget of 5
+	if _, err := cw.Send(msg); err != clockwork.ErrBudgetExceeded {
+		t.Errorf("Fail: err - got %v want %v", err, clockwork.ErrBudgetExceeded)
+	}
+
+	daily, monthly := cw.Budget.Remaining()
+	if daily != 3 || monthly != -1 {
+		t.Errorf("Fail: remaining - got %d, %d want 3, -1", daily, monthly)
+	}
+}
+
+// TestSendBudgetRefunds
+func TestSendBudgetRefunds(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		status   int
+		wantUsed int
+	}{
+		{"api error", "Error 3: Insufficient Credit", 200, 0},
+		{"parse error", "<html>Bad Gateway</html>", 200, 1},
+		{"status code", "", 502, 1},
+	}
+
+	for _, tc := range testCases {
+		cw := clockwork.New(testAPIKey)
+		cw.Budget = &clockwork.Budget{Daily: 2}
+		cw.Client = doerFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: tc.status,
+				Body:       ioutil.NopCloser(strings.NewReader(tc.body)),
+			}, nil
+		})
+
+		// the API may have sent a message whose answer was lost, so it still
+		// counts against the budget
+		msg := clockwork.SMS{To: clockwork.Numbers{"441234567890"}, Content: "Gophers rule!"}
+		for i := 0; i < 10; i++ {
+			cw.Send(msg)
+		}
+		wantUsed := tc.wantUsed * 2
+		if daily, _ := cw.Budget.Used(); daily != wantUsed {
+			t.Errorf("Fail: %s used - got %d want %d", tc.name, daily, wantUsed)
+		}
+	}
+
+	cw := clockwork.New(testAPIKey)
+	cw.Budget = &clockwork.Budget{Daily: 2}
+	cw.Client = doerFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection reset")
+	})
+	cw.Send(clockwork.SMS{To: clockwork.Numbers{"441234567890"}, Content: "Gophers rule!"})
+	if daily, _ := cw.Budget.Used(); daily != 1 {
+		t.Errorf("Fail: transport error used - got %d want 1", daily)
+	}
+}
@@ -0,0 +1,126 @@
+package clockwork_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/umahmood/clockwork"
+)
+
+// TestBulkSender
+func TestBulkSender(t *testing.T) {
+	var mu sync.Mutex
+	var active, maxActive int
+
+	cw := clockwork.New(testAPIKey)
+	cw.Client = doerFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+
+		to := req.URL.Query().Get("To")
+		body := fmt.Sprintf("To: %s ID: VE_%s", to, to)
+		if to == "123" {
+			body = "To: 123 Error 10: Invalid 'To' Parameter"
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+		}, nil
+	})
+
+	msgs := make(chan clockwork.SMS)
+	go func() {
+		defer close(msgs)
+		for i := 0; i < 20; i++ {
+			msgs <- clockwork.SMS{To: clockwork.Numbers{fmt.Sprintf("4412345678%02d", i)}, Content: "Gophers rule!"}
+		}
+		msgs <- clockwork.SMS{To: clockwork.Numbers{"123"}, Content: "Gophers rule!"}
+	}()
+
+	bs := &clockwork.BulkSender{Sender: cw, Workers: 3}
+	seen := make(map[int]bool)
+	for r := range bs.Run(context.Background(), msgs) {
+		seen[r.Index] = true
+		if r.Index == 20 {
+			if r.Err != clockwork.ErrInvalidTo {
+				t.Errorf("Fail: index 20 err - got %v want %v", r.Err, clockwork.ErrInvalidTo)
+			}
+			continue
+		}
+		if r.Err != nil {
+			t.Errorf("Fail: index %d err - got %v want nil", r.Index, r.Err)
+		}
+		if want := "VE_" + r.SMS.To[0]; r.Response[r.SMS.To[0]]["ID"] != want {
+			t.Errorf("Fail: index %d - got %v want ID %s", r.Index, r.Response, want)
+		}
+	}
+
+	if len(seen) != 21 {
+		t.Errorf("Fail: results - got %d want 21", len(seen))
+	}
+
+	if maxActive > 3 {
+		t.Errorf("Fail: concurrency - got %d want <= 3", maxActive)
+	}
+
+	got := bs.Progress()
+	want := clockwork.BulkProgress{Sent: 20, Failed: 1, Parts: 20}
+	if got != want {
+		t.Errorf("Fail: progress - got %+v want %+v", got, want)
+	}
+}
+
+// TestBulkSenderStop
+func TestBulkSenderStop(t *testing.T) {
+	cw := clockwork.New(testAPIKey)
+	cw.Client = doerFunc(func(req *http.Request) (*http.Response, error) {
+		body := "To: 441234567890 ID: VE_1"
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+		}, nil
+	})
+
+	// an endless stream of messages
+	next := func() (clockwork.SMS, bool) {
+		return clockwork.SMS{To: clockwork.Numbers{"441234567890"}}, true
+	}
+
+	bs := &clockwork.BulkSender{Sender: cw, Workers: 2}
+	results := bs.RunFunc(context.Background(), next)
+	for i := 0; i < 10; i++ {
+		<-results
+	}
+	bs.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		for range results {
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Fail: results not closed after Stop")
+	}
+
+	if got := bs.Progress().Sent; got < 10 {
+		t.Errorf("Fail: sent - got %d want >= 10", got)
+	}
+}
@@ -0,0 +1,102 @@
+package clockwork_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/umahmood/clockwork"
+)
+
+// newEchoClockwork returns a Clockwork which accepts every message, recording
+// the ClientID of each request
+func newEchoClockwork(clientIDs *[]string) *clockwork.Clockwork {
+	cw := clockwork.New(testAPIKey)
+	cw.Client = doerFunc(func(req *http.Request) (*http.Response, error) {
+		*clientIDs = append(*clientIDs, req.URL.Query().Get("ClientID"))
+		body := fmt.Sprintf("To: %s ID: VE_1", req.URL.Query().Get("To"))
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+		}, nil
+	})
+	return cw
+}
+
+// TestIdempotencyKey
+func TestIdempotencyKey(t *testing.T) {
+	var clientIDs []string
+	cw := newEchoClockwork(&clientIDs)
+
+	msg := clockwork.SMS{
+		To:             clockwork.Numbers{"441234567890"},
+		Content:        "Gophers rule!",
+		IdempotencyKey: "order-42",
+	}
+	resp, err := cw.Send(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cw.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	msg.IdempotencyKey = "order-43"
+	if _, err := cw.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if clientIDs[0] == "" || clientIDs[0] != clientIDs[1] {
+		t.Errorf("Fail: same key - got %v want equal client ids", clientIDs[:2])
+	}
+	if clientIDs[2] == clientIDs[0] {
+		t.Errorf("Fail: different key - got %v want different client ids", clientIDs)
+	}
+	if len(clientIDs[0]) > 50 {
+		t.Errorf("Fail: client id %s longer than 50 characters", clientIDs[0])
+	}
+	if got := resp["441234567890"]["ClientID"]; got != clientIDs[0] {
+		t.Errorf("Fail: response client id - got %s want %s", got, clientIDs[0])
+	}
+}
+
+// TestGenerateClientID
+func TestGenerateClientID(t *testing.T) {
+	var clientIDs []string
+	cw := newEchoClockwork(&clientIDs)
+	msg := clockwork.SMS{To: clockwork.Numbers{"441234567890"}, Content: "Gophers rule!"}
+
+	// not generated unless asked for
+	if _, err := cw.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	cw.GenerateClientID = true
+	for i := 0; i < 2; i++ {
+		if _, err := cw.Send(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a caller supplied id is kept
+	msg.ClientID = "mine"
+	if _, err := cw.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if clientIDs[0] != "" {
+		t.Errorf("Fail: client id - got %s want none", clientIDs[0])
+	}
+	if clientIDs[1] == "" || clientIDs[1] == clientIDs[2] {
+		t.Errorf("Fail: generated - got %v want two different client ids", clientIDs[1:3])
+	}
+	if clientIDs[3] != "mine" {
+		t.Errorf("Fail: client id - got %s want mine", clientIDs[3])
+	}
+
+	msg.ClientID = strings.Repeat("x", 51)
+	if _, err := cw.Send(msg); err != clockwork.ErrLongClientID {
+		t.Errorf("Fail: err - got %v want %v", err, clockwork.ErrLongClientID)
+	}
+}
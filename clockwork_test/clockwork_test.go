@@ -35,6 +35,14 @@ func (m *mockClockwork) Do(req *http.Request) (*http.Response, error) {
 	return m.f(req)
 }
 
+// doerFunc adapts a function to the clockwork.Doer interface
+type doerFunc func(req *http.Request) (*http.Response, error)
+
+// Do mock HTTP request
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
 // TestSingleValidSMSNumber
 func TestSingleValidSMSNumber(t *testing.T) {
 	mock := &mockClockwork{
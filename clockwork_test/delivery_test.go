@@ -0,0 +1,348 @@
+package clockwork_test
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/umahmood/clockwork"
+)
+
+// TestMain silences the receipt handler's logging
+func TestMain(m *testing.M) {
+	log.SetOutput(ioutil.Discard)
+	os.Exit(m.Run())
+}
+
+// TestReceiptHandlerStatusCodes
+func TestReceiptHandlerStatusCodes(t *testing.T) {
+	testCases := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   int
+	}{
+		{"get", "GET", "/receipts?msg_id=LA_1&status=DELIVRD", "", http.StatusOK},
+		{"post", "POST", "/receipts", "msg_id=LA_1&status=DELIVRD", http.StatusOK},
+		{"put", "PUT", "/receipts", "msg_id=LA_1&status=DELIVRD", http.StatusMethodNotAllowed},
+		{"empty", "GET", "/receipts", "", http.StatusBadRequest},
+		{"missing status", "GET", "/receipts?msg_id=LA_1", "", http.StatusBadRequest},
+		{"bad body", "POST", "/receipts", "msg_id=%zz", http.StatusBadRequest},
+		{"too large", "POST", "/receipts", "msg_id=LA_1&status=DELIVRD&pad=" + strings.Repeat("x", 100), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tc := range testCases {
+		var calls int
+		rh := &clockwork.ReceiptHandler{
+			Path:        "/receipts",
+			MaxBodySize: 64,
+			Callback:    func(clockwork.Receipt) { calls++ },
+		}
+		req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+		rec := httptest.NewRecorder()
+		rh.ServeHTTP(rec, req)
+
+		if rec.Code != tc.want {
+			t.Errorf("Fail: %s - got %d want %d", tc.name, rec.Code, tc.want)
+		}
+		if wantCalls := map[bool]int{true: 1, false: 0}[tc.want == http.StatusOK]; calls != wantCalls {
+			t.Errorf("Fail: %s - callback calls got %d want %d", tc.name, calls, wantCalls)
+		}
+	}
+}
+
+// TestReceiptHandlerAuth
+func TestReceiptHandlerAuth(t *testing.T) {
+	rh := &clockwork.ReceiptHandler{
+		Path:            "/receipts",
+		Secret:          "s3cret",
+		AllowedNetworks: []string{"203.0.113.0/24", "2001:db8::/32"},
+		TrustedProxies:  []string{"10.0.0.1"},
+		Callback:        func(clockwork.Receipt) {},
+	}
+
+	const receipt = "msg_id=LA_1&status=DELIVRD"
+	testCases := []struct {
+		name      string
+		target    string
+		remote    string
+		forwarded string
+		want      int
+	}{
+		{"query token", "/receipts?token=s3cret&" + receipt, "203.0.113.7:1234", "", http.StatusOK},
+		{"path token", "/receipts/s3cret?" + receipt, "203.0.113.7:1234", "", http.StatusOK},
+		{"ipv6", "/receipts?token=s3cret&" + receipt, "[2001:db8::1]:1234", "", http.StatusOK},
+		{"no token", "/receipts?" + receipt, "203.0.113.7:1234", "", http.StatusForbidden},
+		{"bad token", "/receipts?token=guess&" + receipt, "203.0.113.7:1234", "", http.StatusForbidden},
+		{"bad network", "/receipts?token=s3cret&" + receipt, "198.51.100.7:1234", "", http.StatusForbidden},
+		{"trusted proxy", "/receipts?token=s3cret&" + receipt, "10.0.0.1:1234", "198.51.100.7, 203.0.113.7", http.StatusOK},
+		{"spoofed forward", "/receipts?token=s3cret&" + receipt, "10.0.0.1:1234", "203.0.113.7, 198.51.100.7", http.StatusForbidden},
+		{"untrusted proxy", "/receipts?token=s3cret&" + receipt, "198.51.100.7:1234", "203.0.113.7", http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest("GET", tc.target, nil)
+		req.RemoteAddr = tc.remote
+		if tc.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", tc.forwarded)
+		}
+		rec := httptest.NewRecorder()
+		rh.ServeHTTP(rec, req)
+
+		if rec.Code != tc.want {
+			t.Errorf("Fail: %s - got %d want %d", tc.name, rec.Code, tc.want)
+		}
+	}
+}
+
+// TestReceiptHandlerServeShutdown
+func TestReceiptHandlerServeShutdown(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var finished bool
+	rh := &clockwork.ReceiptHandler{
+		Path: "/receipts",
+		Callback: func(clockwork.Receipt) {
+			close(started)
+			<-release
+			finished = true
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error)
+	go func() { served <- rh.Serve(ctx, l) }()
+
+	go http.Get("http://" + l.Addr().String() + "/receipts?msg_id=LA_1&status=DELIVRD")
+	<-started
+	cancel()
+
+	// shutdown waits for the in-flight callback
+	select {
+	case err := <-served:
+		t.Fatalf("Fail: Serve returned %v before callback finished", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Fail: err - got %v want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Fail: Serve did not return after shutdown")
+	}
+	if !finished {
+		t.Errorf("Fail: callback did not finish")
+	}
+
+	if _, err := http.Get("http://" + l.Addr().String() + "/receipts"); err == nil {
+		t.Errorf("Fail: listener still accepting after shutdown")
+	}
+}
+
+// TestReceiptHandlerChannel
+func TestReceiptHandlerChannel(t *testing.T) {
+	testCases := []struct {
+		name     string
+		overflow clockwork.OverflowPolicy
+		want     int
+		queued   int
+	}{
+		{"drop", clockwork.OverflowDrop, http.StatusOK, 1},
+		{"reject", clockwork.OverflowReject, http.StatusServiceUnavailable, 1},
+		{"block", clockwork.OverflowBlock, http.StatusServiceUnavailable, 1},
+	}
+
+	for _, tc := range testCases {
+		receipts := make(chan clockwork.Receipt, 1)
+		rh := &clockwork.ReceiptHandler{
+			Path:     "/receipts",
+			Receipts: receipts,
+			Overflow: tc.overflow,
+		}
+
+		// the first receipt fills the channel, the second overflows it
+		var rec *httptest.ResponseRecorder
+		for _, id := range []string{"LA_1", "LA_2"} {
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			req := httptest.NewRequest("GET", "/receipts?msg_id="+id+"&status=DELIVRD", nil).WithContext(ctx)
+			rec = httptest.NewRecorder()
+			rh.ServeHTTP(rec, req)
+			cancel()
+		}
+
+		if rec.Code != tc.want {
+			t.Errorf("Fail: %s - got %d want %d", tc.name, rec.Code, tc.want)
+		}
+		if tc.want == http.StatusServiceUnavailable && rec.Header().Get("Retry-After") == "" {
+			t.Errorf("Fail: %s - missing Retry-After header", tc.name)
+		}
+		if len(receipts) != tc.queued {
+			t.Errorf("Fail: %s - queued got %d want %d", tc.name, len(receipts), tc.queued)
+		}
+		if r := <-receipts; r.ID != "LA_1" {
+			t.Errorf("Fail: %s - receipt got %s want LA_1", tc.name, r.ID)
+		}
+	}
+}
+
+// TestReceiptHandlerCallbackPanic
+func TestReceiptHandlerCallbackPanic(t *testing.T) {
+	rh := &clockwork.ReceiptHandler{
+		Path:     "/receipts",
+		Callback: func(clockwork.Receipt) { panic("boom") },
+	}
+	req := httptest.NewRequest("GET", "/receipts?msg_id=LA_1&status=DELIVRD", nil)
+	rec := httptest.NewRecorder()
+	rh.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Fail: got %d want %d", rec.Code, http.StatusInternalServerError)
+	}
+}
+
+// TestReceiptFields
+func TestReceiptFields(t *testing.T) {
+	var got clockwork.Receipt
+	rh := &clockwork.ReceiptHandler{
+		Path:     "/receipts",
+		Secret:   "s3cret",
+		Callback: func(r clockwork.Receipt) { got = r },
+	}
+	target := "/receipts?token=s3cret&msg_id=LA_1&to=441234567890&client_id=order-42&status=UNDELIV&detail=4&timestamp=1483228800&network=23415"
+	req := httptest.NewRequest("GET", target, nil)
+	rec := httptest.NewRecorder()
+	rh.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Fail: got %d want %d", rec.Code, http.StatusOK)
+	}
+	if got.ClientID != "order-42" {
+		t.Errorf("Fail: ClientID - got %s want order-42", got.ClientID)
+	}
+	if got.RawStatus != "UNDELIV" || got.Status != clockwork.Undelivered {
+		t.Errorf("Fail: status - got %s %v want UNDELIV Undelivered", got.RawStatus, got.Status)
+	}
+	if got.RawDetail != "4" || got.Err == nil {
+		t.Errorf("Fail: detail - got %s %v want 4 and an error", got.RawDetail, got.Err)
+	}
+	if want := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC); !got.NetworkTime.Equal(want) {
+		t.Errorf("Fail: NetworkTime - got %v want %v", got.NetworkTime, want)
+	}
+	if got.Time.IsZero() {
+		t.Errorf("Fail: Time not set")
+	}
+	if got.Values.Get("network") != "23415" {
+		t.Errorf("Fail: Values network - got %s want 23415", got.Values.Get("network"))
+	}
+	if _, ok := got.Values["token"]; ok {
+		t.Errorf("Fail: Values contains the secret token")
+	}
+}
+
+// TestParseDeliveryState
+func TestParseDeliveryState(t *testing.T) {
+	testCases := []struct {
+		status  string
+		want    clockwork.DeliveryState
+		final   bool
+		success bool
+	}{
+		{"QUEUED", clockwork.Queued, false, false},
+		{"ENROUTE", clockwork.Enroute, false, false},
+		{"DELIVRD", clockwork.Delivered, true, true},
+		{" delivrd ", clockwork.Delivered, true, true},
+		{"EXPIRED", clockwork.Expired, true, false},
+		{"DELETED", clockwork.Deleted, true, false},
+		{"UNDELIV", clockwork.Undelivered, true, false},
+		{"ACCEPTD", clockwork.Accepted, true, false},
+		{"UNKNOWN", clockwork.Unknown, true, false},
+		{"REJECTD", clockwork.Rejected, true, false},
+		{"BUFFRED", clockwork.Unrecognised, false, false},
+	}
+
+	for _, tc := range testCases {
+		got, err := clockwork.ParseDeliveryState(tc.status)
+		if got != tc.want {
+			t.Errorf("Fail: %q - got %v want %v", tc.status, got, tc.want)
+		}
+		if (err != nil) != (tc.want == clockwork.Unrecognised) {
+			t.Errorf("Fail: %q err - got %v", tc.status, err)
+		}
+		if got.IsFinal() != tc.final {
+			t.Errorf("Fail: %q IsFinal - got %t want %t", tc.status, got.IsFinal(), tc.final)
+		}
+		if got.IsSuccess() != tc.success {
+			t.Errorf("Fail: %q IsSuccess - got %t want %t", tc.status, got.IsSuccess(), tc.success)
+		}
+	}
+
+	_, err := clockwork.ParseDeliveryState("BUFFRED")
+	if e, ok := err.(*clockwork.UnrecognisedStateError); !ok || e.Status != "BUFFRED" {
+		t.Errorf("Fail: err - got %#v want *UnrecognisedStateError", err)
+	}
+	if s := clockwork.Unrecognised.String(); s != "Unrecognised" {
+		t.Errorf("Fail: String - got %s want Unrecognised", s)
+	}
+}
+
+// TestReceiptUnrecognisedState
+func TestReceiptUnrecognisedState(t *testing.T) {
+	var got clockwork.Receipt
+	rh := &clockwork.ReceiptHandler{
+		Path:     "/receipts",
+		Callback: func(r clockwork.Receipt) { got = r },
+	}
+	rec := httptest.NewRecorder()
+	rh.ServeHTTP(rec, httptest.NewRequest("GET", "/receipts?msg_id=LA_1&status=BUFFRED", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Fail: got %d want %d", rec.Code, http.StatusOK)
+	}
+	if got.Status != clockwork.Unrecognised || got.RawStatus != "BUFFRED" {
+		t.Errorf("Fail: status - got %v %s want Unrecognised BUFFRED", got.Status, got.RawStatus)
+	}
+}
+
+// TestReceiptHandlerCallbackAndChannel
+func TestReceiptHandlerCallbackAndChannel(t *testing.T) {
+	var calls int
+	rh := &clockwork.ReceiptHandler{
+		Path:     "/receipts",
+		Callback: func(clockwork.Receipt) { calls++ },
+		Receipts: make(chan clockwork.Receipt, 1),
+	}
+	rec := httptest.NewRecorder()
+	rh.ServeHTTP(rec, httptest.NewRequest("GET", "/receipts?msg_id=LA_1&status=DELIVRD", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Fail: got %d want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if calls != 0 {
+		t.Errorf("Fail: callback calls - got %d want 0", calls)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if err := rh.Serve(context.Background(), l); err == nil {
+		t.Errorf("Fail: Serve err - got nil want an error")
+	}
+}
@@ -0,0 +1,55 @@
+package clockwork_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/umahmood/clockwork"
+)
+
+// TestDestinationPolicy
+func TestDestinationPolicy(t *testing.T) {
+	policy := &clockwork.DestinationPolicy{
+		Allow: []string{"+44", "00353"},
+		Deny:  []string{"449"},
+	}
+
+	testCases := []struct {
+		number string
+		want   bool
+	}{
+		{"441234567890", true},
+		{"+353 85 123 4567", true},
+		{"449012345678", false},
+		{"13053696625", false},
+		{"882123456789", false},
+	}
+	for _, tc := range testCases {
+		if got := policy.Permitted(tc.number); got != tc.want {
+			t.Errorf("Fail: %s - got %v want %v", tc.number, got, tc.want)
+		}
+	}
+}
+
+// TestSendBlockedDestination
+func TestSendBlockedDestination(t *testing.T) {
+	cw := clockwork.New(testAPIKey)
+	cw.Destinations = &clockwork.DestinationPolicy{Allow: []string{"44"}}
+	cw.Client = doerFunc(func(req *http.Request) (*http.Response, error) {
+		t.Errorf("Fail: request made for blocked destination")
+		return nil, nil
+	})
+
+	resp, err := cw.Send(clockwork.SMS{
+		To:      clockwork.Numbers{"441234567890", "882123456789"},
+		Content: "Gophers rule!",
+	})
+	if err != clockwork.ErrBlockedDestination {
+		t.Errorf("Fail: err - got %v want %v", err, clockwork.ErrBlockedDestination)
+	}
+
+	got := resp.Blocked()
+	if len(got) != 1 || got[0] != "882123456789" {
+		t.Errorf("Fail: blocked - got %v want [882123456789]", got)
+	}
+}
@@ -0,0 +1,200 @@
+package clockwork_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/umahmood/clockwork"
+)
+
+// TestMailMerge
+func TestMailMerge(t *testing.T) {
+	input := `number,name,client_id
++44 1234 567890,Ann,
+0044 7700 900123,Bob,
+447700900124,Ann,
+447700900125,Ann,ref-1
+123,Dan,
+447700900126,,
+`
+
+	var sends []clockwork.SMS
+	send := func(ctx context.Context, sms clockwork.SMS) (clockwork.SMSResponse, error) {
+		sends = append(sends, sms)
+		resp := make(clockwork.SMSResponse)
+		for _, n := range sms.To {
+			resp[n] = map[string]string{"ID": "VE_" + n}
+		}
+		return resp, nil
+	}
+
+	var out bytes.Buffer
+	summary, err := clockwork.MailMerge(context.Background(), senderFunc(send), strings.NewReader(input), &out, clockwork.MailMergeOptions{
+		SMS:      clockwork.SMS{From: "Gopher", IdempotencyKey: "campaign-1"},
+		Template: "Hi {{.name}}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := clockwork.MailMergeSummary{Rows: 6, Sent: 5, Failed: 1}
+	if summary != want {
+		t.Errorf("Fail: summary - got %+v want %+v", summary, want)
+	}
+
+	// Ann x2 batched, Bob, Ann with a client id alone, and the empty name
+	// renders "Hi " which is still sent
+	gotSends := make([]string, len(sends))
+	for i, s := range sends {
+		gotSends[i] = fmt.Sprintf("%s:%s:%s", strings.Join(s.To, "+"), s.Content, s.ClientID)
+		if s.IdempotencyKey != "" {
+			t.Errorf("Fail: send %d idempotency key - got %q want empty", i, s.IdempotencyKey)
+		}
+	}
+	wantSends := []string{
+		"441234567890+447700900124:Hi Ann:",
+		"447700900123:Hi Bob:",
+		"447700900125:Hi Ann:ref-1",
+		"447700900126:Hi :",
+	}
+	if strings.Join(gotSends, ",") != strings.Join(wantSends, ",") {
+		t.Errorf("Fail: sends - got %v want %v", gotSends, wantSends)
+	}
+
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(records[0], ","); got != "number,name,client_id,message_id,error" {
+		t.Errorf("Fail: header - got %s", got)
+	}
+	if got := strings.Join(records[2], ","); got != "447700900123,Bob,,VE_447700900123," {
+		t.Errorf("Fail: row 2 - got %s", got)
+	}
+	if records[5][3] != "" || records[5][4] != clockwork.ErrInvalidTo.Error() {
+		t.Errorf("Fail: row 5 - got %v want invalid number error", records[5])
+	}
+}
+
+// TestMailMergeContentColumn
+func TestMailMergeContentColumn(t *testing.T) {
+	input := "Number,Content\n441234567890,Gophers rule!\n447700900123,\n"
+	send := func(ctx context.Context, sms clockwork.SMS) (clockwork.SMSResponse, error) {
+		return nil, clockwork.ErrInsufficientCredit
+	}
+
+	var out bytes.Buffer
+	summary, err := clockwork.MailMerge(context.Background(), senderFunc(send), strings.NewReader(input), &out, clockwork.MailMergeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Failed != 2 {
+		t.Errorf("Fail: failed - got %d want 2", summary.Failed)
+	}
+
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records[1][3] != clockwork.ErrInsufficientCredit.Error() {
+		t.Errorf("Fail: row 1 - got %v want insufficient credit", records[1])
+	}
+	if records[2][3] != clockwork.ErrMissingContent.Error() {
+		t.Errorf("Fail: row 2 - got %v want missing content", records[2])
+	}
+}
+
+// TestMailMergeBlockedDestination
+func TestMailMergeBlockedDestination(t *testing.T) {
+	input := `number,content
+441234567890,Hello
+19005551234,Hello
+447700900123,Hello
+`
+	cw := clockwork.New(testAPIKey)
+	cw.Destinations = &clockwork.DestinationPolicy{Allow: []string{"44"}}
+	var requests int
+	cw.Client = doerFunc(func(req *http.Request) (*http.Response, error) {
+		requests++
+		var lines []string
+		for _, n := range strings.Split(req.URL.Query().Get("To"), ",") {
+			lines = append(lines, fmt.Sprintf("To: %s ID: VE_%s", n, n))
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(strings.Join(lines, "\n"))),
+		}, nil
+	})
+
+	var out bytes.Buffer
+	summary, err := clockwork.MailMerge(context.Background(), cw, strings.NewReader(input), &out, clockwork.MailMergeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only the blocked row fails, the rest of its batch is sent
+	want := clockwork.MailMergeSummary{Rows: 3, Sent: 2, Failed: 1}
+	if summary != want {
+		t.Errorf("Fail: summary - got %+v want %+v", summary, want)
+	}
+	if requests != 1 {
+		t.Errorf("Fail: requests - got %d want 1", requests)
+	}
+
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := records[2][3]; got != clockwork.ErrBlockedDestination.Error() {
+		t.Errorf("Fail: blocked row error - got %q want %q", got, clockwork.ErrBlockedDestination)
+	}
+	if got := records[3][2]; got != "VE_447700900123" {
+		t.Errorf("Fail: allowed row message id - got %q want VE_447700900123", got)
+	}
+}
+
+// TestMailMergeWritesAsItGoes
+func TestMailMergeWritesAsItGoes(t *testing.T) {
+	input := `number,content
+441234567890,Hello
+447700900123,Goodbye
+447700900124,Hello again
+`
+	var out bytes.Buffer
+	var written []string
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	send := func(ctx context.Context, sms clockwork.SMS) (clockwork.SMSResponse, error) {
+		written = append(written, out.String())
+		if len(written) == 2 {
+			// killed part way through the second batch
+			cancel()
+			return nil, ctx.Err()
+		}
+		return clockwork.SMSResponse{sms.To[0]: {"ID": "VE_" + sms.To[0]}}, nil
+	}
+
+	_, err := clockwork.MailMerge(ctx, senderFunc(send), strings.NewReader(input), &out, clockwork.MailMergeOptions{})
+	if err != context.Canceled {
+		t.Errorf("Fail: err - got %v want %v", err, context.Canceled)
+	}
+
+	// the first row was in the output before the second batch was sent
+	if !strings.Contains(written[1], "441234567890,Hello,VE_441234567890,") {
+		t.Errorf("Fail: output before second batch - got %q", written[1])
+	}
+
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || records[3][3] != context.Canceled.Error() {
+		t.Errorf("Fail: output - got %v want every row, the last cancelled", records)
+	}
+}
@@ -0,0 +1,154 @@
+package clockwork_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/umahmood/clockwork"
+)
+
+// scriptedChecker returns balances and errors in order
+type scriptedChecker struct {
+	amounts []int64
+	errs    []error
+}
+
+// BalanceContext mock balance
+func (s *scriptedChecker) BalanceContext(ctx context.Context) (clockwork.Balance, error) {
+	amount, err := s.amounts[0], s.errs[0]
+	if len(s.amounts) > 1 {
+		s.amounts, s.errs = s.amounts[1:], s.errs[1:]
+	}
+	if err != nil {
+		return clockwork.Balance{}, err
+	}
+	return clockwork.Balance{Amount: amount, Currency: "GBP"}, nil
+}
+
+// TestCreditMonitor
+func TestCreditMonitor(t *testing.T) {
+	errDown := errors.New("down")
+	checker := &scriptedChecker{
+		amounts: []int64{1000, 400, 300, 50, 0, 0, 40, 1000},
+		errs:    []error{nil, nil, nil, nil, errDown, errDown, nil, nil},
+	}
+
+	var events []string
+	m := &clockwork.CreditMonitor{
+		Checker:    checker,
+		Warn:       500,
+		Critical:   100,
+		OnWarn:     func(b clockwork.Balance) { events = append(events, "warn "+b.String()) },
+		OnCritical: func(b clockwork.Balance) { events = append(events, "critical "+b.String()) },
+		OnRecover:  func(b clockwork.Balance) { events = append(events, "recover "+b.String()) },
+		OnError:    func(err error) { events = append(events, "error "+err.Error()) },
+	}
+
+	for range checker.amounts {
+		m.Check()
+	}
+
+	want := []string{
+		"warn 4.00 GBP",
+		"critical 0.50 GBP",
+		"error down",
+		"recover 10.00 GBP",
+	}
+	if len(events) != len(want) {
+		t.Fatalf("Fail: events - got %v want %v", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("Fail: event %d - got %s want %s", i, events[i], want[i])
+		}
+	}
+
+	last, _, err := m.Last()
+	if err != nil || last.Amount != 1000 {
+		t.Errorf("Fail: last - got %v, %v want 10.00 GBP, nil", last, err)
+	}
+}
+
+// TestCreditMonitorRun
+func TestCreditMonitorRun(t *testing.T) {
+	critical := make(chan clockwork.Balance, 1)
+	m := &clockwork.CreditMonitor{
+		Checker:    &scriptedChecker{amounts: []int64{0}, errs: []error{nil}},
+		Interval:   time.Millisecond,
+		OnCritical: func(b clockwork.Balance) { critical <- b },
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- m.Run(ctx) }()
+
+	select {
+	case <-critical:
+	case <-time.After(time.Second):
+		t.Errorf("Fail: OnCritical not called")
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Fail: err - got %v want %v", err, context.Canceled)
+	}
+
+	if m.Level() != clockwork.CreditCritical {
+		t.Errorf("Fail: level - got %v want %v", m.Level(), clockwork.CreditCritical)
+	}
+}
+
+// TestCreditMonitorTooPrecise
+func TestCreditMonitorTooPrecise(t *testing.T) {
+	cw := clockwork.New(testAPIKey)
+	cw.Client = mockBody("Balance: 3.999 (GBP)")
+
+	var errs int
+	m := &clockwork.CreditMonitor{
+		Checker:  cw,
+		Warn:     500,
+		Critical: 100,
+		OnError:  func(error) { errs++ },
+	}
+
+	b, err := m.Check()
+	if err != nil || errs != 0 {
+		t.Fatalf("Fail: err - got %v want nil", err)
+	}
+	if b.Amount != 399 || b.Currency != "GBP" {
+		t.Errorf("Fail: balance - got %d %s want 399 GBP", b.Amount, b.Currency)
+	}
+	if m.Level() != clockwork.CreditWarning {
+		t.Errorf("Fail: level - got %v want %v", m.Level(), clockwork.CreditWarning)
+	}
+}
+
+// blockingChecker blocks until ctx is done
+type blockingChecker struct{}
+
+// BalanceContext mock balance
+func (blockingChecker) BalanceContext(ctx context.Context) (clockwork.Balance, error) {
+	<-ctx.Done()
+	return clockwork.Balance{}, ctx.Err()
+}
+
+// TestCreditMonitorRunCancelsCheck
+func TestCreditMonitorRunCancelsCheck(t *testing.T) {
+	m := &clockwork.CreditMonitor{Checker: blockingChecker{}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- m.Run(ctx) }()
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("Fail: err - got %v want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Fail: Run did not return after cancel")
+	}
+}
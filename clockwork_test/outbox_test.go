@@ -0,0 +1,140 @@
+package clockwork_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/umahmood/clockwork"
+)
+
+// senderFunc adapts a function to the clockwork.Sender interface
+type senderFunc func(ctx context.Context, sms clockwork.SMS) (clockwork.SMSResponse, error)
+
+// SendContext mock send
+func (f senderFunc) SendContext(ctx context.Context, sms clockwork.SMS) (clockwork.SMSResponse, error) {
+	return f(ctx, sms)
+}
+
+// TestOutbox
+func TestOutbox(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clockwork")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := clockwork.NewFileOutboxStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first attempt fails with a network error, the second succeeds
+	var calls int
+	var clientIDs []string
+	send := func(ctx context.Context, sms clockwork.SMS) (clockwork.SMSResponse, error) {
+		calls++
+		clientIDs = append(clientIDs, sms.ClientID)
+		if !sms.UniqueIDChecks {
+			t.Errorf("Fail: unique id checks not enabled")
+		}
+		if calls == 1 {
+			return nil, errors.New("connection reset")
+		}
+		return clockwork.SMSResponse{"441234567890": {"ID": "VE_1"}}, nil
+	}
+
+	ob := &clockwork.Outbox{Store: store, Sender: senderFunc(send), RetryDelay: time.Nanosecond}
+	id, err := ob.Enqueue(clockwork.SMS{To: clockwork.Numbers{"441234567890"}, Content: "Gophers rule!"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a fresh outbox over the same store, as after a restart
+	ob = &clockwork.Outbox{Store: store, Sender: senderFunc(send), RetryDelay: time.Nanosecond}
+	for i := 0; i < 3; i++ {
+		if err := ob.Drain(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("Fail: calls - got %d want 2", calls)
+	}
+	if clientIDs[0] == "" || clientIDs[0] != clientIDs[1] {
+		t.Errorf("Fail: client ids - got %v want the same id on retry", clientIDs)
+	}
+
+	e, err := store.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.State != clockwork.OutboxSent || e.Attempts != 2 {
+		t.Errorf("Fail: entry - got %s after %d attempts want sent after 2", e.State, e.Attempts)
+	}
+	if e.Response["441234567890"]["ID"] != "VE_1" {
+		t.Errorf("Fail: response - got %v want ID VE_1", e.Response)
+	}
+
+	// sent entries are moved out of the way of Pending
+	if paths, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(paths) != 0 {
+		t.Errorf("Fail: pending files - got %v want none", paths)
+	}
+	if err := store.Delete(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(id); err != clockwork.ErrOutboxEntryNotFound {
+		t.Errorf("Fail: Get after Delete err - got %v want %v", err, clockwork.ErrOutboxEntryNotFound)
+	}
+}
+
+// TestOutboxOutcomes
+func TestOutboxOutcomes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clockwork")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := clockwork.NewFileOutboxStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		err  error
+		want clockwork.OutboxState
+	}{
+		{clockwork.ErrDuplicateClientID, clockwork.OutboxSent},
+		{clockwork.ErrMissingContent, clockwork.OutboxFailed},
+		{clockwork.ErrInternal, clockwork.OutboxPending},
+	}
+
+	for _, tc := range testCases {
+		sendErr := tc.err
+		ob := &clockwork.Outbox{
+			Store: store,
+			Sender: senderFunc(func(ctx context.Context, sms clockwork.SMS) (clockwork.SMSResponse, error) {
+				return nil, sendErr
+			}),
+		}
+		id, err := ob.Enqueue(clockwork.SMS{To: clockwork.Numbers{"441234567890"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ob.Drain(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		e, err := store.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.State != tc.want {
+			t.Errorf("Fail: %v - got %s want %s", tc.err, e.State, tc.want)
+		}
+	}
+}
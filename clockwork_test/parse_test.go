@@ -0,0 +1,165 @@
+package clockwork_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/umahmood/clockwork"
+)
+
+// mockBody returns a mock which responds to every request with body
+func mockBody(body string) *mockClockwork {
+	return &mockClockwork{
+		apiKey: testAPIKey,
+		f: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(strings.NewReader(body)),
+			}, nil
+		},
+	}
+}
+
+// TestSendResponseWhitespace
+func TestSendResponseWhitespace(t *testing.T) {
+	body := "\r\n\tTo: 441234567890\tID: VE_439221450\r\n   \r\nTo:  13053696625 ID: LA_360224205  \r\n\n"
+
+	resp, err := mockBody(body).Send(clockwork.SMS{})
+	if err != nil {
+		t.Fatalf("Fail: err - got %v want nil", err)
+	}
+
+	if resp["441234567890"]["ID"] != "VE_439221450" {
+		t.Errorf("Fail: resp - got %v want 441234567890 ID VE_439221450", resp)
+	}
+
+	if resp["13053696625"]["ID"] != "LA_360224205" {
+		t.Errorf("Fail: resp - got %v want 13053696625 ID LA_360224205", resp)
+	}
+}
+
+// TestSendResponseSingleDigitError
+func TestSendResponseSingleDigitError(t *testing.T) {
+	_, err := mockBody("Error 3: Insufficient Credit").Send(clockwork.SMS{})
+	if err != clockwork.ErrInsufficientCredit {
+		t.Errorf("Fail: err - got %v want %v", err, clockwork.ErrInsufficientCredit)
+	}
+}
+
+// TestResponseParseError
+func TestResponseParseError(t *testing.T) {
+	testCases := []struct {
+		body     string
+		wantLine string
+	}{
+		{"", ""},
+		{" \r\n\t", ""},
+		{"<html><body>Bad Gateway</body></html>", "<html><body>Bad Gateway</body></html>"},
+		{"To: 441234567890", "To: 441234567890"},
+		{"To: 441234567890 ID: VE_1\nNewField: x", "NewField: x"},
+		{"Error 99999999999999999999: Overflow", "Error 99999999999999999999: Overflow"},
+	}
+
+	for _, tc := range testCases {
+		_, err := mockBody(tc.body).Send(clockwork.SMS{})
+		perr, ok := err.(*clockwork.ParseError)
+		if !ok {
+			t.Errorf("Fail: send %q - got %v want *ParseError", tc.body, err)
+			continue
+		}
+		if perr.Line != tc.wantLine {
+			t.Errorf("Fail: send %q - line got %q want %q", tc.body, perr.Line, tc.wantLine)
+		}
+
+		_, _, err = mockBody(tc.body).Credit()
+		if _, ok := err.(*clockwork.ParseError); !ok {
+			t.Errorf("Fail: credit %q - got %v want *ParseError", tc.body, err)
+		}
+	}
+}
+
+// FuzzParseSendResponse
+func FuzzParseSendResponse(f *testing.F) {
+	f.Add("To: 441234567890 ID: VE_439221450")
+	f.Add("To: 123 Error 10: Invalid 'To' Parameter\nTo: 441234567890 ID: VE_439221450")
+	f.Add("Error 58: Invalid API Key")
+	f.Add("To: \r\n\t ID:")
+	f.Add("<html>\n</html>")
+	f.Fuzz(func(t *testing.T, body string) {
+		resp, err := mockBody(body).Send(clockwork.SMS{})
+		if err == nil && len(resp) == 0 {
+			t.Errorf("Fail: body %q - no error and no numbers", body)
+		}
+	})
+}
+
+// FuzzParseCreditResponse
+func FuzzParseCreditResponse(f *testing.F) {
+	f.Add("Balance: 287.58 (GBP)")
+	f.Add("Balance: -94.23 (GBP)\r\n")
+	f.Add("Error 58: Invalid API Key")
+	f.Add("Balance: (GBP)")
+	f.Fuzz(func(t *testing.T, body string) {
+		_, code, err := mockBody(body).Credit()
+		if err == nil && code == "" {
+			t.Errorf("Fail: body %q - no error and no currency code", body)
+		}
+	})
+}
+
+// TestSendResponseExtraFields
+func TestSendResponseExtraFields(t *testing.T) {
+	body := "To: 441234567890 ID: VE_439221450 ClientID: order 42\nTo: 13053696625 ID: LA_360224205"
+
+	resp, err := mockBody(body).Send(clockwork.SMS{})
+	if err != nil {
+		t.Fatalf("Fail: err - got %v want nil", err)
+	}
+
+	want := map[string]string{"ID": "VE_439221450", "ClientID": "order 42"}
+	got := resp["441234567890"]
+	if len(got) != len(want) {
+		t.Errorf("Fail: fields - got %v want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("Fail: %s - got %q want %q", k, got[k], v)
+		}
+	}
+
+	if len(resp["13053696625"]) != 1 {
+		t.Errorf("Fail: fields - got %v want only ID", resp["13053696625"])
+	}
+}
+
+// TestSendResponseClientIDLooksLikeField
+func TestSendResponseClientIDLooksLikeField(t *testing.T) {
+	testCases := []struct {
+		name string
+		line string
+		want map[string]string
+	}{
+		{"repeats ID key", "To: 441234567890 ID: VE_1 ClientID: ref ID: 7", map[string]string{"ID": "VE_1", "ClientID": "ref ID: 7"}},
+		{"key and value", "To: 441234567890 ID: VE_1 ClientID: order: 42", map[string]string{"ID": "VE_1", "ClientID": "order: 42"}},
+		{"field before", "To: 441234567890 ID: VE_1 Network: 23415 ClientID: ref To: 1", map[string]string{"ID": "VE_1", "Network": "23415", "ClientID": "ref To: 1"}},
+	}
+
+	for _, tc := range testCases {
+		resp, err := mockBody(tc.line).Send(clockwork.SMS{})
+		if err != nil {
+			t.Errorf("Fail: %s err - got %v want nil", tc.name, err)
+			continue
+		}
+		got := resp["441234567890"]
+		if len(got) != len(tc.want) {
+			t.Errorf("Fail: %s fields - got %v want %v", tc.name, got, tc.want)
+		}
+		for k, v := range tc.want {
+			if got[k] != v {
+				t.Errorf("Fail: %s %s - got %q want %q", tc.name, k, got[k], v)
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package clockwork_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/umahmood/clockwork"
+)
+
+// newLimitedClockwork returns a Clockwork which answers every request
+// successfully, limited to messages per second and one status request per
+// second
+func newLimitedClockwork(failFast bool, messages float64) *clockwork.Clockwork {
+	cw := clockwork.New(testAPIKey)
+	cw.Limiter = &clockwork.RateLimiter{
+		Send:     clockwork.RateLimit{Messages: messages, Parts: 100},
+		Status:   clockwork.RateLimit{Messages: 1},
+		FailFast: failFast,
+	}
+	cw.Client = doerFunc(func(req *http.Request) (*http.Response, error) {
+		body := "To: 441234567890 ID: VE_1"
+		if strings.HasSuffix(req.URL.Path, "balance") {
+			body = "Balance: 1.00 (GBP)"
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+		}, nil
+	})
+	return cw
+}
+
+// TestRateLimitFailFast
+func TestRateLimitFailFast(t *testing.T) {
+	cw := newLimitedClockwork(true, 1)
+	msg := clockwork.SMS{To: clockwork.Numbers{"441234567890"}, Content: "Gophers rule!"}
+
+	if _, err := cw.Send(msg); err != nil {
+		t.Fatalf("Fail: first send err - got %v want nil", err)
+	}
+
+	if _, err := cw.Send(msg); err != clockwork.ErrRateLimited {
+		t.Errorf("Fail: second send err - got %v want %v", err, clockwork.ErrRateLimited)
+	}
+
+	// status requests are limited separately
+	if _, err := cw.Balance(); err != nil {
+		t.Errorf("Fail: balance err - got %v want nil", err)
+	}
+
+	if _, err := cw.Balance(); err != clockwork.ErrRateLimited {
+		t.Errorf("Fail: balance err - got %v want %v", err, clockwork.ErrRateLimited)
+	}
+}
+
+// TestRateLimitFailFastOverBurst
+func TestRateLimitFailFastOverBurst(t *testing.T) {
+	cw := newLimitedClockwork(true, 5)
+	var to clockwork.Numbers
+	for i := 0; i < 10; i++ {
+		to = append(to, "44123456789"+strconv.Itoa(i))
+	}
+	msg := clockwork.SMS{To: to, Content: "Gophers rule!"}
+
+	// more numbers than the burst are sent once the bucket is full
+	if _, err := cw.Send(msg); err != nil {
+		t.Fatalf("Fail: first send err - got %v want nil", err)
+	}
+
+	if _, err := cw.Send(msg); err != clockwork.ErrRateLimited {
+		t.Errorf("Fail: second send err - got %v want %v", err, clockwork.ErrRateLimited)
+	}
+}
+
+// TestRateLimitWait
+func TestRateLimitWait(t *testing.T) {
+	cw := newLimitedClockwork(false, 10)
+	var to clockwork.Numbers
+	for i := 0; i < 10; i++ {
+		to = append(to, "44123456789"+strconv.Itoa(i))
+	}
+	msg := clockwork.SMS{To: clockwork.Numbers{"441234567890"}, Content: "Gophers rule!"}
+
+	// empty the bucket
+	start := time.Now()
+	if _, err := cw.Send(clockwork.SMS{To: to, Content: "Gophers rule!"}); err != nil {
+		t.Fatalf("Fail: first send err - got %v want nil", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := cw.SendContext(ctx, msg); err != context.DeadlineExceeded {
+		t.Errorf("Fail: err - got %v want %v", err, context.DeadlineExceeded)
+	}
+
+	// the next send waits for a token to be refilled, a tenth of a second
+	// after the bucket was emptied
+	if _, err := cw.Send(msg); err != nil {
+		t.Fatalf("Fail: err - got %v want nil", err)
+	}
+	if waited := time.Since(start); waited < 100*time.Millisecond {
+		t.Errorf("Fail: waited %v want at least 100ms", waited)
+	}
+}
@@ -0,0 +1,107 @@
+package clockwork_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/umahmood/clockwork"
+)
+
+// TestReceiptHandlerDedup
+func TestReceiptHandlerDedup(t *testing.T) {
+	var got []string
+	fail := true
+	rh := &clockwork.ReceiptHandler{
+		Path:  "/receipts",
+		Dedup: clockwork.NewMemoryDedupStore(),
+		Callback: func(r clockwork.Receipt) {
+			if fail {
+				fail = false
+				panic("crash before ack")
+			}
+			got = append(got, r.ID+" "+r.Status.String())
+		},
+	}
+
+	targets := []string{
+		"/receipts?msg_id=LA_1&status=ENROUTE", // rejected by the callback
+		"/receipts?msg_id=LA_1&status=ENROUTE", // retry is delivered
+		"/receipts?msg_id=LA_1&status=ENROUTE", // duplicate
+		"/receipts?msg_id=LA_1&status=DELIVRD",
+		"/receipts?msg_id=LA_2&status=DELIVRD",
+		"/receipts?msg_id=LA_1&status=DELIVRD", // duplicate
+	}
+	for _, target := range targets {
+		rec := httptest.NewRecorder()
+		rh.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		if rec.Code != http.StatusOK && rec.Code != http.StatusInternalServerError {
+			t.Errorf("Fail: %s - got %d", target, rec.Code)
+		}
+	}
+
+	want := []string{"LA_1 Enroute", "LA_1 Delivered", "LA_2 Delivered"}
+	if len(got) != len(want) {
+		t.Fatalf("Fail: delivered got %v want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Fail: delivered[%d] got %s want %s", i, got[i], want[i])
+		}
+	}
+}
+
+// TestMemoryDedupStoreWindow
+func TestMemoryDedupStoreWindow(t *testing.T) {
+	s := clockwork.NewMemoryDedupStore()
+	start := time.Date(2017, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.Add("a", start)
+
+	if ok, _ := s.Seen("a", start.Add(-time.Hour)); !ok {
+		t.Errorf("Fail: seen within window - got false want true")
+	}
+	if ok, _ := s.Seen("a", start.Add(time.Second)); ok {
+		t.Errorf("Fail: seen outside window - got true want false")
+	}
+
+	// adding after the window prunes the stale key
+	s.Add("b", start.Add(2*time.Minute))
+	if s.Len() != 1 {
+		t.Errorf("Fail: len - got %d want 1", s.Len())
+	}
+}
+
+// TestReceiptHandlerDedupConcurrent
+func TestReceiptHandlerDedupConcurrent(t *testing.T) {
+	var calls int32
+	rh := &clockwork.ReceiptHandler{
+		Path:  "/receipts",
+		Dedup: clockwork.NewMemoryDedupStore(),
+		Callback: func(clockwork.Receipt) {
+			atomic.AddInt32(&calls, 1)
+			// hold the receipt so the retries arrive while it is in flight
+			time.Sleep(20 * time.Millisecond)
+		},
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			rh.ServeHTTP(rec, httptest.NewRequest("GET", "/receipts?msg_id=LA_1&status=DELIVRD", nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("Fail: got %d want %d", rec.Code, http.StatusOK)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("Fail: callback calls - got %d want 1", calls)
+	}
+}
@@ -0,0 +1,131 @@
+package clockwork_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/umahmood/clockwork"
+)
+
+// TestReceiptJournalReplay
+func TestReceiptJournalReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "receipts.log")
+
+	journal, err := clockwork.OpenReceiptJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rh := &clockwork.ReceiptHandler{
+		Path:     "/receipts",
+		Secret:   "s3cret",
+		Journal:  journal,
+		Callback: func(clockwork.Receipt) { panic("crash after journal") },
+	}
+	for _, target := range []string{
+		"/receipts?token=s3cret&msg_id=LA_1&to=441234567890&status=DELIVRD",
+		"/receipts?token=s3cret&msg_id=LA_2&status=UNDELIV&detail=4",
+	} {
+		rec := httptest.NewRecorder()
+		rh.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("Fail: %s - got %d want %d", target, rec.Code, http.StatusInternalServerError)
+		}
+	}
+	journal.Close()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cret") {
+		t.Errorf("Fail: journal contains the secret token")
+	}
+
+	// simulate a crash part way through writing a third receipt
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	f.WriteString(`{"time":"2017-01-01T00:00:00Z","val`)
+	f.Close()
+
+	var got []clockwork.Receipt
+	n, err := clockwork.ReplayReceiptJournal(path, func(r clockwork.Receipt) { got = append(got, r) })
+	if err != nil {
+		t.Fatalf("Fail: err - got %v want nil", err)
+	}
+	if n != 2 || len(got) != 2 {
+		t.Fatalf("Fail: replayed - got %d want 2", n)
+	}
+	if got[0].ID != "LA_1" || got[0].To != "441234567890" || got[0].Status != clockwork.Delivered {
+		t.Errorf("Fail: receipt 0 - got %+v", got[0])
+	}
+	if got[1].ID != "LA_2" || got[1].Status != clockwork.Undelivered || got[1].Err == nil {
+		t.Errorf("Fail: receipt 1 - got %+v", got[1])
+	}
+	if got[0].Time.IsZero() {
+		t.Errorf("Fail: receipt time not kept")
+	}
+}
+
+// TestReplayReceiptJournalMissing
+func TestReplayReceiptJournalMissing(t *testing.T) {
+	n, err := clockwork.ReplayReceiptJournal(filepath.Join(os.TempDir(), "no-such-journal"), func(clockwork.Receipt) {})
+	if n != 0 || err != nil {
+		t.Errorf("Fail: got %d, %v want 0, nil", n, err)
+	}
+}
+
+// TestReceiptJournalReopenAfterCrash
+func TestReceiptJournalReopenAfterCrash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "receipts.log")
+
+	serve := func(ids ...string) {
+		journal, err := clockwork.OpenReceiptJournal(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer journal.Close()
+		rh := &clockwork.ReceiptHandler{
+			Path:     "/receipts",
+			Journal:  journal,
+			Callback: func(clockwork.Receipt) {},
+		}
+		for _, id := range ids {
+			rec := httptest.NewRecorder()
+			rh.ServeHTTP(rec, httptest.NewRequest("GET", "/receipts?msg_id="+id+"&status=DELIVRD", nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("Fail: %s - got %d want %d", id, rec.Code, http.StatusOK)
+			}
+		}
+	}
+
+	serve("LA_1")
+
+	// crash part way through writing a receipt, then restart
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	f.WriteString(`{"time":"2017-01-01T00:00:00Z","val`)
+	f.Close()
+	serve("LA_2", "LA_3")
+
+	var got []string
+	n, err := clockwork.ReplayReceiptJournal(path, func(r clockwork.Receipt) { got = append(got, r.ID) })
+	if err != nil {
+		t.Fatalf("Fail: err - got %v want nil", err)
+	}
+	if n != 3 || strings.Join(got, ",") != "LA_1,LA_2,LA_3" {
+		t.Errorf("Fail: replayed - got %v want [LA_1 LA_2 LA_3]", got)
+	}
+}
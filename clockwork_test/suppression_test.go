@@ -0,0 +1,209 @@
+package clockwork_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/umahmood/clockwork"
+)
+
+// TestSendSuppressed
+func TestSendSuppressed(t *testing.T) {
+	var gotTo string
+	cw := clockwork.New(testAPIKey)
+	cw.Suppression = clockwork.NewMemorySuppressionList("+44 1234 567890")
+	cw.Client = doerFunc(func(req *http.Request) (*http.Response, error) {
+		gotTo = req.URL.Query().Get("To")
+		body := "To: 13053696625 ID: LA_360224205"
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+		}, nil
+	})
+
+	msg := clockwork.SMS{
+		To:      clockwork.Numbers{"441234567890", "13053696625"},
+		Content: "Gophers rule!",
+	}
+
+	resp, err := cw.Send(msg)
+	if err != nil {
+		t.Fatalf("Fail: err - got %v want nil", err)
+	}
+
+	if gotTo != "13053696625" {
+		t.Errorf("Fail: to - got %s want %s", gotTo, "13053696625")
+	}
+
+	if resp["13053696625"]["ID"] != "LA_360224205" {
+		t.Errorf("Fail: valid number 13053696625 not in map")
+	}
+
+	got := resp.Suppressed()
+	if len(got) != 1 || got[0] != "441234567890" {
+		t.Errorf("Fail: suppressed - got %v want [441234567890]", got)
+	}
+}
+
+// TestSendAllSuppressed
+func TestSendAllSuppressed(t *testing.T) {
+	cw := clockwork.New(testAPIKey)
+	cw.Suppression = clockwork.NewMemorySuppressionList("441234567890")
+	cw.Client = doerFunc(func(req *http.Request) (*http.Response, error) {
+		t.Errorf("Fail: request made for suppressed number")
+		return nil, nil
+	})
+
+	resp, err := cw.Send(clockwork.SMS{
+		To:      clockwork.Numbers{"441234567890"},
+		Content: "Gophers rule!",
+	})
+	if err != nil {
+		t.Errorf("Fail: err - got %v want nil", err)
+	}
+
+	if resp["441234567890"]["Suppressed"] != "true" {
+		t.Errorf("Fail: resp - got %v want number suppressed", resp)
+	}
+}
+
+// TestFileSuppressionList
+func TestFileSuppressionList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clockwork")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "stop.txt")
+
+	list, err := clockwork.NewFileSuppressionList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []string{"441234567890", "0013053696625", "447700900123"} {
+		if err := list.Add(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := list.Remove("+44 7700 900123"); err != nil {
+		t.Fatal(err)
+	}
+
+	// reload from disk
+	list, err = clockwork.NewFileSuppressionList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		number string
+		want   bool
+	}{
+		{"441234567890", true},
+		{"+1 305 369 6625", true},
+		{"447700900123", false},
+	}
+	for _, tc := range testCases {
+		got, err := list.Suppressed(tc.number)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("Fail: %s - got %v want %v", tc.number, got, tc.want)
+		}
+	}
+}
+
+// TestSendSuppressedRestInvalid
+func TestSendSuppressedRestInvalid(t *testing.T) {
+	cw := clockwork.New(testAPIKey)
+	cw.Suppression = clockwork.NewMemorySuppressionList("441234567890")
+	cw.Client = doerFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader("Error 10: Invalid 'To' Parameter")),
+		}, nil
+	})
+
+	resp, err := cw.Send(clockwork.SMS{
+		To:      clockwork.Numbers{"441234567890", "123"},
+		Content: "Gophers rule!",
+	})
+	if err == nil {
+		t.Errorf("Fail: err - got nil want an error")
+	}
+
+	got := resp.Suppressed()
+	if len(got) != 1 || got[0] != "441234567890" {
+		t.Errorf("Fail: suppressed - got %v want [441234567890]", got)
+	}
+}
+
+// TestFileSuppressionListRemoveFails
+func TestFileSuppressionListRemoveFails(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clockwork")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "stop.txt")
+
+	list, err := clockwork.NewFileSuppressionList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := list.Add("441234567890"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a non-empty directory in place of the file cannot be replaced
+	os.Remove(path)
+	if err := os.MkdirAll(filepath.Join(path, "x"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := list.Remove("441234567890"); err == nil {
+		t.Fatalf("Fail: err - got nil want an error")
+	}
+	if ok, _ := list.Suppressed("441234567890"); !ok {
+		t.Errorf("Fail: number removed from memory after the file rewrite failed")
+	}
+	if paths, _ := filepath.Glob(filepath.Join(dir, "*.tmp*")); len(paths) != 0 {
+		t.Errorf("Fail: temporary files left behind - got %v", paths)
+	}
+}
+
+// TestFileSuppressionListRemoveKeepsComments
+func TestFileSuppressionListRemoveKeepsComments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clockwork")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "stop.txt")
+
+	text := "# replied STOP\n+44 1234 567890\n\n# complaint, ticket 42\n+44 7700 900123\n441234567890\n"
+	if err := ioutil.WriteFile(path, []byte(text), 0600); err != nil {
+		t.Fatal(err)
+	}
+	list, err := clockwork.NewFileSuppressionList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := list.Remove("441234567890"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# replied STOP\n\n# complaint, ticket 42\n+44 7700 900123\n"
+	if string(data) != want {
+		t.Errorf("Fail: file - got %q want %q", data, want)
+	}
+}
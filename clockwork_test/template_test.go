@@ -0,0 +1,108 @@
+package clockwork_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/umahmood/clockwork"
+)
+
+// TestTemplateRender
+func TestTemplateRender(t *testing.T) {
+	tmpl, err := clockwork.NewTemplate(clockwork.SMS{From: "Gopher"}, "Hi {{.name}}, see you {{.day}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := func(name, day string) map[string]interface{} {
+		return map[string]interface{}{"name": name, "day": day}
+	}
+	recipients := []clockwork.Recipient{
+		{Number: "441234567890", Data: data("Ann", "Monday")},
+		{Number: "447700900123", Data: data("Bob", "Monday")},
+		{Number: "447700900124", Data: data("Ann", "Monday")},
+		{Number: "447700900125", Data: map[string]interface{}{"name": "Cat"}},
+		{Number: "447700900126", Data: data("Дима", "Monday")},
+		{Number: "447700900127", Data: data(strings.Repeat("x", 160), "Monday")},
+	}
+
+	msgs, err := tmpl.Render(recipients)
+
+	errs, ok := err.(clockwork.RenderErrors)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("Fail: err - got %v want 3 render errors", err)
+	}
+	if errs[0].Number != "447700900125" {
+		t.Errorf("Fail: missing key - got %s want 447700900125", errs[0].Number)
+	}
+	if errs[1].Err != clockwork.ErrInvalidCharInContent {
+		t.Errorf("Fail: non gsm - got %v want %v", errs[1].Err, clockwork.ErrInvalidCharInContent)
+	}
+	if errs[2].Err != clockwork.ErrMessageTooLong {
+		t.Errorf("Fail: too long - got %v want %v", errs[2].Err, clockwork.ErrMessageTooLong)
+	}
+
+	if len(msgs) != 2 {
+		t.Fatalf("Fail: messages - got %d want 2", len(msgs))
+	}
+	want := []struct {
+		content string
+		to      string
+	}{
+		{"Hi Ann, see you Monday", "441234567890,447700900124"},
+		{"Hi Bob, see you Monday", "447700900123"},
+	}
+	for i, w := range want {
+		if msgs[i].Content != w.content || strings.Join(msgs[i].To, ",") != w.to {
+			t.Errorf("Fail: message %d - got %q to %v want %q to %s", i, msgs[i].Content, msgs[i].To, w.content, w.to)
+		}
+		if msgs[i].From != "Gopher" {
+			t.Errorf("Fail: message %d from - got %s want Gopher", i, msgs[i].From)
+		}
+	}
+}
+
+// TestTemplateBatchSize
+func TestTemplateBatchSize(t *testing.T) {
+	tmpl, err := clockwork.NewTemplate(clockwork.SMS{}, "Gophers rule!")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var recipients []clockwork.Recipient
+	for i := 0; i < 120; i++ {
+		recipients = append(recipients, clockwork.Recipient{Number: fmt.Sprintf("44770090%04d", i)})
+	}
+
+	msgs, err := tmpl.Render(recipients)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(msgs) != 3 || len(msgs[0].To) != 50 || len(msgs[1].To) != 50 || len(msgs[2].To) != 20 {
+		t.Errorf("Fail: batches - got %d messages want 50, 50, 20 numbers", len(msgs))
+	}
+}
+
+// TestTemplateClearsClientID
+func TestTemplateClearsClientID(t *testing.T) {
+	base := clockwork.SMS{ClientID: "campaign-1", IdempotencyKey: "campaign-1"}
+	tmpl, err := clockwork.NewTemplate(base, "Hi {{.name}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, err := tmpl.Render([]clockwork.Recipient{
+		{Number: "441234567890", Data: map[string]interface{}{"name": "Ann"}},
+		{Number: "447700900123", Data: map[string]interface{}{"name": "Bob"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range msgs {
+		if m.ClientID != "" || m.IdempotencyKey != "" {
+			t.Errorf("Fail: message %d - got ClientID %q IdempotencyKey %q want both empty", i, m.ClientID, m.IdempotencyKey)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package clockwork_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/umahmood/clockwork"
+)
+
+// TestTracker
+func TestTracker(t *testing.T) {
+	tr := clockwork.NewTracker()
+	tr.Track(clockwork.SMSResponse{
+		"441234567890": {"ID": "LA_1", "ClientID": "order-1"},
+		"441234567891": {"ID": "LA_2", "ClientID": "order-1"},
+		"441234567892": {"Suppressed": "true"},
+	})
+
+	start := time.Date(2017, 1, 1, 12, 0, 0, 0, time.UTC)
+	receipts := []struct {
+		receipt clockwork.Receipt
+		err     error
+	}{
+		{clockwork.Receipt{ID: "LA_1", Status: clockwork.Enroute, RawStatus: "ENROUTE", Time: start}, nil},
+		{clockwork.Receipt{ID: "LA_1", Status: clockwork.Enroute, RawStatus: "ENROUTE", Time: start}, nil},
+		{clockwork.Receipt{ID: "LA_1", Status: clockwork.Delivered, RawStatus: "DELIVRD", Time: start.Add(time.Second)}, nil},
+		{clockwork.Receipt{ID: "LA_1", Status: clockwork.Enroute, RawStatus: "ENROUTE", Time: start.Add(2 * time.Second)}, clockwork.ErrInvalidTransition},
+		{clockwork.Receipt{ID: "LA_2", Status: clockwork.Undelivered, RawStatus: "UNDELIV", Time: start, Err: clockwork.ErrTempOperator}, nil},
+		{clockwork.Receipt{ID: "LA_2", Status: clockwork.Delivered, RawStatus: "DELIVRD", Time: start}, clockwork.ErrInvalidTransition},
+		// a receipt arriving before Send returns is still tracked
+		{clockwork.Receipt{ID: "LA_3", To: "441234567893", ClientID: "order-2", Status: clockwork.Enroute, RawStatus: "ENROUTE", Time: start}, nil},
+	}
+	for i, r := range receipts {
+		if err := tr.Update(r.receipt); err != r.err {
+			t.Errorf("Fail: receipt %d - got %v want %v", i, err, r.err)
+		}
+	}
+
+	m, ok := tr.Get("LA_1")
+	if !ok {
+		t.Fatalf("Fail: LA_1 not tracked")
+	}
+	if m.State != clockwork.Delivered || m.To != "441234567890" || m.ClientID != "order-1" {
+		t.Errorf("Fail: LA_1 - got %+v", m)
+	}
+	if len(m.History) != 2 || m.History[0].Status != clockwork.Enroute || m.History[1].Status != clockwork.Delivered {
+		t.Errorf("Fail: LA_1 history - got %+v", m.History)
+	}
+
+	if m, _ := tr.Get("LA_2"); m.State != clockwork.Undelivered || m.Err != clockwork.ErrTempOperator {
+		t.Errorf("Fail: LA_2 - got %v %v want Undelivered %v", m.State, m.Err, clockwork.ErrTempOperator)
+	}
+	if _, ok := tr.Get("LA_4"); ok {
+		t.Errorf("Fail: LA_4 tracked")
+	}
+
+	if msgs := tr.ByClientID("order-1"); len(msgs) != 2 {
+		t.Errorf("Fail: ByClientID order-1 - got %d want 2", len(msgs))
+	}
+	if msgs := tr.ByNumber("+44 1234 567893"); len(msgs) != 1 || msgs[0].ID != "LA_3" {
+		t.Errorf("Fail: ByNumber - got %+v want LA_3", msgs)
+	}
+	if msgs := tr.ByNumber("441234567892"); len(msgs) != 0 {
+		t.Errorf("Fail: ByNumber suppressed - got %d want 0", len(msgs))
+	}
+
+	// only final messages are pruned
+	if n := tr.Prune(time.Now().Add(time.Hour)); n != 2 {
+		t.Errorf("Fail: Prune - got %d want 2", n)
+	}
+	if msgs := tr.ByClientID("order-1"); len(msgs) != 0 {
+		t.Errorf("Fail: ByClientID after prune - got %d want 0", len(msgs))
+	}
+	if _, ok := tr.Get("LA_3"); !ok {
+		t.Errorf("Fail: LA_3 pruned")
+	}
+
+	// a receipt without a number arriving before Send returns is filled in
+	// by Track
+	tr.Update(clockwork.Receipt{ID: "LA_5", Status: clockwork.Enroute, RawStatus: "ENROUTE", Time: start})
+	tr.Track(clockwork.SMSResponse{"441234567895": {"ID": "LA_5"}})
+	if m, _ := tr.Get("LA_5"); m.To != "441234567895" || m.State != clockwork.Enroute {
+		t.Errorf("Fail: LA_5 - got %s %v want 441234567895 Enroute", m.To, m.State)
+	}
+	if msgs := tr.ByNumber("441234567895"); len(msgs) != 1 || msgs[0].ID != "LA_5" {
+		t.Errorf("Fail: ByNumber LA_5 - got %+v want LA_5", msgs)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/umahmood/clockwork"
+)
+
+// errNoKey no API key was found
+var errNoKey = errors.New("no API key, use -key, set CLOCKWORK_API_KEY or add 'key = ...' to the config file")
+
+// client returns a Clockwork instance for the API key
+func (e *env) client() (*clockwork.Clockwork, error) {
+	if e.key == "" {
+		return nil, errNoKey
+	}
+	return clockwork.New(e.key), nil
+}
+
+// runSend sends an SMS message
+func runSend(e *env, args []string) error {
+	flags := flag.NewFlagSet("send", flag.ExitOnError)
+	to := flags.String("to", "", "comma separated numbers to send to")
+	from := flags.String("from", e.config["from"], "sender name or number")
+	content := flags.String("m", "", "message content, read from stdin if empty")
+	msgType := flags.String("type", clockwork.TEXT, "message type, TEXT or UCS2")
+	concat := flags.Int("concat", 0, "maximum message parts, 1 to 3")
+	clientID := flags.String("client-id", "", "unique message ID")
+	flags.Parse(args)
+
+	if *to == "" {
+		return errors.New("send: -to is required")
+	}
+	if *content == "" {
+		b, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		*content = strings.TrimRight(string(b), "\n")
+	}
+
+	cw, err := e.client()
+	if err != nil {
+		return err
+	}
+	resp, sendErr := cw.Send(clockwork.SMS{
+		To:       clockwork.Numbers(strings.Split(*to, ",")),
+		From:     *from,
+		Content:  *content,
+		MsgType:  strings.ToUpper(*msgType),
+		Concat:   *concat,
+		ClientID: *clientID,
+	})
+	if resp == nil && sendErr != nil {
+		return sendErr
+	}
+
+	nums := make([]string, 0, len(resp))
+	for n := range resp {
+		nums = append(nums, n)
+	}
+	sort.Strings(nums)
+	for _, n := range nums {
+		v := map[string]string{"to": n}
+		for k, val := range resp[n] {
+			v[strings.ToLower(k)] = val
+		}
+		if err := e.print(v, fmt.Sprintf("%s %s", n, resp[n]["ID"])); err != nil {
+			return err
+		}
+	}
+	return sendErr
+}
+
+// runCredit prints the account balance
+func runCredit(e *env, args []string) error {
+	flags := flag.NewFlagSet("credit", flag.ExitOnError)
+	flags.Parse(args)
+
+	cw, err := e.client()
+	if err != nil {
+		return err
+	}
+	b, err := cw.Balance()
+	if err != nil {
+		return err
+	}
+	return e.print(balanceJSON(b), b.String())
+}
+
+// runStatus checks the API key works and prints the account status
+func runStatus(e *env, args []string) error {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	flags.Parse(args)
+
+	cw, err := e.client()
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	b, err := cw.Balance()
+	status := map[string]interface{}{
+		"version":    clockwork.Version(),
+		"key_source": e.keySource,
+		"latency_ms": time.Since(start).Nanoseconds() / int64(time.Millisecond),
+		"ok":         err == nil,
+	}
+	lines := []string{
+		"version:    " + clockwork.Version(),
+		"key source: " + e.keySource,
+	}
+	if err != nil {
+		status["error"] = err.Error()
+		lines = append(lines, "status:     error - "+err.Error())
+	} else {
+		status["balance"] = balanceJSON(b)
+		lines = append(lines, "status:     ok", "balance:    "+b.String())
+		if b.AccountType != "" {
+			lines = append(lines, "account:    "+b.AccountType)
+		}
+	}
+	if perr := e.print(status, strings.Join(lines, "\n")); perr != nil {
+		return perr
+	}
+	return err
+}
+
+// balanceJSON returns b in JSON form
+func balanceJSON(b clockwork.Balance) map[string]interface{} {
+	v := map[string]interface{}{
+		"amount":      b.Decimal(),
+		"minor_units": b.Amount,
+		"currency":    b.Currency,
+	}
+	if b.AccountType != "" {
+		v["account_type"] = b.AccountType
+	}
+	if len(b.Fields) > 0 {
+		v["fields"] = b.Fields
+	}
+	return v
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// config name/value settings read from the config file
+type config map[string]string
+
+// defaultConfigPath returns ~/.clockwork, or an empty string if there is no
+// home directory
+func defaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".clockwork")
+}
+
+// loadConfig reads the config file at path. A missing file is an empty
+// config. Blank lines and lines starting with '#' are ignored.
+func loadConfig(path string) (config, error) {
+	cfg := make(config)
+	if path == "" {
+		return cfg, nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("%s:%d: expected 'name = value'", path, n)
+		}
+		name := strings.ToLower(strings.TrimSpace(line[:i]))
+		cfg[name] = strings.Trim(strings.TrimSpace(line[i+1:]), `"`)
+	}
+	return cfg, scanner.Err()
+}
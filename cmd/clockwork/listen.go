@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/umahmood/clockwork"
+)
+
+// runListen listens for delivery receipts and prints each one, optionally
+// appending them to a log file as JSON lines
+func runListen(e *env, args []string) error {
+	flags := flag.NewFlagSet("listen", flag.ExitOnError)
+	port := flags.Int("port", 9090, "port to listen on")
+	addr := flags.String("addr", "", "interface address to listen on, all interfaces if empty")
+	certFile := flags.String("tls-cert", "", "TLS certificate file, serve HTTPS with -tls-key")
+	keyFile := flags.String("tls-key", "", "TLS key file")
+	path := flags.String("path", "/receipts", "URL path receipts are sent to")
+	logPath := flags.String("log", "", "append receipts to this file as JSON lines")
+	flags.Parse(args)
+
+	var log io.Writer
+	if *logPath != "" {
+		f, err := openReceiptLog(*logPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		log = f
+	}
+
+	// receipts arrive concurrently, keep lines whole
+	var mu sync.Mutex
+	onReceipt := func(r clockwork.Receipt) {
+		mu.Lock()
+		defer mu.Unlock()
+		v := receiptJSON(r)
+		if err := e.print(v, receiptText(r)); err != nil {
+			fmt.Fprintln(os.Stderr, "clockwork:", err)
+		}
+		if log != nil {
+			if err := logReceipt(log, v); err != nil {
+				fmt.Fprintln(os.Stderr, "clockwork:", err)
+			}
+		}
+	}
+
+	// finish writing in-flight receipts on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Fprintf(os.Stderr, "listening for receipts on %s%s\n", net.JoinHostPort(*addr, strconv.Itoa(*port)), *path)
+	return clockwork.DeliveryReceiptListenContext(ctx, &clockwork.ReceiptHandler{
+		Path:     *path,
+		Port:     *port,
+		Addr:     *addr,
+		CertFile: *certFile,
+		KeyFile:  *keyFile,
+		Callback: onReceipt,
+	})
+}
+
+// openReceiptLog opens the receipt log at path for appending, creating it if
+// it does not exist
+func openReceiptLog(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+}
+
+// logReceipt appends v to w as a single JSON line
+func logReceipt(w io.Writer, v map[string]interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// receiptText returns r as a human readable line e.g.
+//
+//	2017-03-17T17:21:27Z LA_422342 441234567890 Undelivered (clockwork: absent subscriber - temporary)
+func receiptText(r clockwork.Receipt) string {
+	status := r.Status.String()
+	if r.Status == clockwork.Unrecognised {
+		status = r.RawStatus
+	}
+	text := fmt.Sprintf("%s %s %s %s", r.Time.Format(time.RFC3339), r.ID, r.To, status)
+	if r.Err != nil {
+		text += " (" + r.Err.Error() + ")"
+	}
+	return text
+}
+
+// receiptJSON returns r in JSON form
+func receiptJSON(r clockwork.Receipt) map[string]interface{} {
+	v := map[string]interface{}{
+		"id":         r.ID,
+		"to":         r.To,
+		"status":     r.Status.String(),
+		"raw_status": r.RawStatus,
+		"time":       r.Time,
+		"values":     r.Values,
+	}
+	if r.ClientID != "" {
+		v["client_id"] = r.ClientID
+	}
+	if !r.NetworkTime.IsZero() {
+		v["network_time"] = r.NetworkTime
+	}
+	if r.Err != nil {
+		v["error"] = r.Err.Error()
+	}
+	return v
+}
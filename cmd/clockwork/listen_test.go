@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/umahmood/clockwork"
+)
+
+var receiptTime = time.Date(2017, 3, 17, 17, 21, 27, 0, time.UTC)
+
+// TestReceiptText
+func TestReceiptText(t *testing.T) {
+	testCases := []struct {
+		name    string
+		receipt clockwork.Receipt
+		want    string
+	}{
+		{
+			"delivered",
+			clockwork.Receipt{ID: "LA_1", To: "441234567890", Status: clockwork.Delivered, RawStatus: "DELIVRD", Time: receiptTime},
+			"2017-03-17T17:21:27Z LA_1 441234567890 Delivered",
+		},
+		{
+			"detail error",
+			clockwork.Receipt{ID: "LA_2", To: "441234567890", Status: clockwork.Undelivered, RawStatus: "UNDELIV", Time: receiptTime, Err: clockwork.ErrTempAbsentSub},
+			"2017-03-17T17:21:27Z LA_2 441234567890 Undelivered (clockwork: absent subscriber - temporary)",
+		},
+		{
+			"unrecognised",
+			clockwork.Receipt{ID: "LA_3", To: "441234567890", Status: clockwork.Unrecognised, RawStatus: "QUEUED_AT_SMSC", Time: receiptTime},
+			"2017-03-17T17:21:27Z LA_3 441234567890 QUEUED_AT_SMSC",
+		},
+	}
+
+	for _, tc := range testCases {
+		if got := receiptText(tc.receipt); got != tc.want {
+			t.Errorf("Fail: %s - got %q want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+// TestReceiptJSON
+func TestReceiptJSON(t *testing.T) {
+	testCases := []struct {
+		name    string
+		receipt clockwork.Receipt
+		want    map[string]interface{}
+		absent  []string
+	}{
+		{
+			"minimal",
+			clockwork.Receipt{ID: "LA_1", To: "441234567890", Status: clockwork.Delivered, RawStatus: "DELIVRD", Time: receiptTime},
+			map[string]interface{}{"id": "LA_1", "to": "441234567890", "status": "Delivered", "raw_status": "DELIVRD"},
+			[]string{"client_id", "network_time", "error"},
+		},
+		{
+			"optional fields",
+			clockwork.Receipt{
+				ID:          "LA_2",
+				To:          "441234567890",
+				Status:      clockwork.Undelivered,
+				RawStatus:   "UNDELIV",
+				Time:        receiptTime,
+				ClientID:    "order-42",
+				NetworkTime: receiptTime.Add(-time.Minute),
+				Err:         clockwork.ErrTempAbsentSub,
+			},
+			map[string]interface{}{
+				"status":    "Undelivered",
+				"client_id": "order-42",
+				"error":     "clockwork: absent subscriber - temporary",
+			},
+			nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		got := receiptJSON(tc.receipt)
+		for key, value := range tc.want {
+			if got[key] != value {
+				t.Errorf("Fail: %s %s - got %v want %v", tc.name, key, got[key], value)
+			}
+		}
+		for _, key := range tc.absent {
+			if _, ok := got[key]; ok {
+				t.Errorf("Fail: %s %s - got %v want no key", tc.name, key, got[key])
+			}
+		}
+		if got["time"] != tc.receipt.Time {
+			t.Errorf("Fail: %s time - got %v want %v", tc.name, got["time"], tc.receipt.Time)
+		}
+		if !tc.receipt.NetworkTime.IsZero() && got["network_time"] != tc.receipt.NetworkTime {
+			t.Errorf("Fail: %s network_time - got %v want %v", tc.name, got["network_time"], tc.receipt.NetworkTime)
+		}
+	}
+}
+
+// TestLogReceiptAppends
+func TestLogReceiptAppends(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clockwork")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "receipts.jsonl")
+
+	// each run of listen appends to what earlier runs wrote
+	for _, id := range []string{"LA_1", "LA_2"} {
+		f, err := openReceiptLog(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := clockwork.Receipt{
+			ID:     id,
+			To:     "441234567890",
+			Status: clockwork.Delivered,
+			Time:   receiptTime,
+			Values: url.Values{"msg_id": {id}},
+		}
+		if err := logReceipt(f, receiptJSON(r)); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var ids []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var v struct {
+			ID     string     `json:"id"`
+			Time   time.Time  `json:"time"`
+			Values url.Values `json:"values"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &v); err != nil {
+			t.Fatalf("Fail: line %q - %v", scanner.Text(), err)
+		}
+		if !v.Time.Equal(receiptTime) || v.Values.Get("msg_id") != v.ID {
+			t.Errorf("Fail: line %q - got time %v values %v", scanner.Text(), v.Time, v.Values)
+		}
+		ids = append(ids, v.ID)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != "LA_1" || ids[1] != "LA_2" {
+		t.Errorf("Fail: ids - got %v want [LA_1 LA_2]", ids)
+	}
+}
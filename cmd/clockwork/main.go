@@ -0,0 +1,120 @@
+// Command clockwork sends SMS messages, checks credit and listens for delivery
+// receipts using the Clockwork SMS service.
+//
+// Usage:
+//
+//	clockwork [-key KEY] [-config FILE] [-json] <command> [arguments]
+//
+// The commands are:
+//
+//	send    send an SMS message
+//	credit  show the account balance
+//	status  check the API key works and show account status
+//	listen  listen for delivery receipts and print each one (alias tail)
+//
+// The API key is read from the -key flag, the CLOCKWORK_API_KEY environment
+// variable or the config file, in that order. The config file (default
+// ~/.clockwork) holds "name = value" lines, e.g.
+//
+//	key = YOUR-API-KEY
+//	from = Gopher
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// usage printed for -h and unknown commands
+const usage = `usage: clockwork [-key KEY] [-config FILE] [-json] <command> [arguments]
+
+commands:
+  send    send an SMS message
+  credit  show the account balance
+  status  check the API key works and show account status
+  listen  listen for delivery receipts and print each one (alias tail)
+
+Run 'clockwork <command> -h' for a command's arguments.
+`
+
+// command a clockwork subcommand
+type command func(env *env, args []string) error
+
+var commands = map[string]command{
+	"send":   runSend,
+	"credit": runCredit,
+	"status": runStatus,
+	"listen": runListen,
+	"tail":   runListen,
+}
+
+// env global options shared by every command
+type env struct {
+	key       string
+	keySource string
+	config    config
+	json      bool
+}
+
+// print writes v as a JSON line if -json was given, otherwise text
+func (e *env) print(v interface{}, text string) error {
+	if e.json {
+		return json.NewEncoder(os.Stdout).Encode(v)
+	}
+	_, err := fmt.Println(text)
+	return err
+}
+
+func main() {
+	flags := flag.NewFlagSet("clockwork", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	key := flags.String("key", "", "Clockwork API key")
+	configPath := flags.String("config", defaultConfigPath(), "config file")
+	jsonOut := flags.Bool("json", false, "print JSON output")
+	flags.Parse(os.Args[1:])
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	run, ok := commands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "clockwork: unknown command %q\n\n", flags.Arg(0))
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fatal(err)
+	}
+	e := &env{config: cfg, json: *jsonOut}
+	e.key, e.keySource = apiKey(*key, cfg, *configPath)
+
+	if err := run(e, flags.Args()[1:]); err != nil {
+		fatal(err)
+	}
+}
+
+// apiKey returns the API key and where it came from, taken from the -key
+// flag, the CLOCKWORK_API_KEY environment variable or the config file read
+// from configPath, in that order. It returns empty strings if none is set.
+func apiKey(flagKey string, cfg config, configPath string) (key, source string) {
+	switch {
+	case flagKey != "":
+		return flagKey, "flag"
+	case os.Getenv("CLOCKWORK_API_KEY") != "":
+		return os.Getenv("CLOCKWORK_API_KEY"), "environment"
+	case cfg["key"] != "":
+		return cfg["key"], configPath
+	}
+	return "", ""
+}
+
+// fatal prints err and exits
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "clockwork:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes a config file holding text and returns its path
+func writeConfig(t *testing.T, text string) string {
+	dir, err := ioutil.TempDir("", "clockwork")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(path, []byte(text), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestLoadConfig
+func TestLoadConfig(t *testing.T) {
+	path := writeConfig(t, `# clockwork settings
+key = "abc123"
+
+From=Gopher
+  # indented comment
+empty =
+`)
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := config{"key": "abc123", "from": "Gopher", "empty": ""}
+	if len(cfg) != len(want) {
+		t.Errorf("Fail: config - got %v want %v", cfg, want)
+	}
+	for name, value := range want {
+		if got, ok := cfg[name]; !ok || got != value {
+			t.Errorf("Fail: %s - got %q want %q", name, got, value)
+		}
+	}
+}
+
+// TestLoadConfigMalformed
+func TestLoadConfigMalformed(t *testing.T) {
+	path := writeConfig(t, "key = abc123\nfrom Gopher\n")
+	_, err := loadConfig(path)
+	if err == nil {
+		t.Fatalf("Fail: err - got nil want an error")
+	}
+	if !strings.Contains(err.Error(), path+":2:") {
+		t.Errorf("Fail: err - got %q want the file and line number", err)
+	}
+}
+
+// TestLoadConfigMissing
+func TestLoadConfigMissing(t *testing.T) {
+	cfg, err := loadConfig(filepath.Join(os.TempDir(), "no-such-clockwork-config"))
+	if err != nil || len(cfg) != 0 {
+		t.Errorf("Fail: got %v, %v want an empty config", cfg, err)
+	}
+}
+
+// TestAPIKeyPrecedence
+func TestAPIKeyPrecedence(t *testing.T) {
+	cfg := config{"key": "from-config"}
+	testCases := []struct {
+		name       string
+		flagKey    string
+		env        string
+		cfg        config
+		wantKey    string
+		wantSource string
+	}{
+		{"flag", "from-flag", "from-env", cfg, "from-flag", "flag"},
+		{"environment", "", "from-env", cfg, "from-env", "environment"},
+		{"config", "", "", cfg, "from-config", "/etc/clockwork"},
+		{"none", "", "", config{}, "", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Setenv("CLOCKWORK_API_KEY", tc.env)
+		key, source := apiKey(tc.flagKey, tc.cfg, "/etc/clockwork")
+		if key != tc.wantKey || source != tc.wantSource {
+			t.Errorf("Fail: %s - got %q from %q want %q from %q", tc.name, key, source, tc.wantKey, tc.wantSource)
+		}
+	}
+}
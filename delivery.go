@@ -1,12 +1,19 @@
 package clockwork
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -34,6 +41,9 @@ const (
 	Unknown
 	// Rejected Message rejected by the mobile network
 	Rejected
+	// Unrecognised the network sent a status this package does not know. The
+	// status is kept in Receipt.RawStatus.
+	Unrecognised
 )
 
 // String returns delivery state as a string
@@ -57,8 +67,54 @@ func (d DeliveryState) String() string {
 		return "Unknown"
 	case Rejected:
 		return "Rejected"
+	case Unrecognised:
+		return "Unrecognised"
 	default:
-		return "!ERROR!" // debug
+		return fmt.Sprintf("DeliveryState(%d)", byte(d))
+	}
+}
+
+// IsFinal reports whether d is a final state, after which no further receipts
+// are expected for the message
+func (d DeliveryState) IsFinal() bool {
+	switch d {
+	case Delivered, Expired, Deleted, Undelivered, Accepted, Unknown, Rejected:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsSuccess reports whether d means the message reached its destination
+func (d DeliveryState) IsSuccess() bool {
+	return d == Delivered
+}
+
+// ParseDeliveryState returns the delivery state for a status sent by the
+// network e.g. "DELIVRD". Case and surrounding space are ignored. An unknown
+// status returns Unrecognised and an *UnrecognisedStateError.
+func ParseDeliveryState(s string) (DeliveryState, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "QUEUED":
+		return Queued, nil
+	case "ENROUTE":
+		return Enroute, nil
+	case "DELIVRD":
+		return Delivered, nil
+	case "EXPIRED":
+		return Expired, nil
+	case "DELETED":
+		return Deleted, nil
+	case "UNDELIV":
+		return Undelivered, nil
+	case "ACCEPTD":
+		return Accepted, nil
+	case "UNKNOWN":
+		return Unknown, nil
+	case "REJECTD":
+		return Rejected, nil
+	default:
+		return Unrecognised, &UnrecognisedStateError{Status: s}
 	}
 }
 
@@ -67,117 +123,413 @@ type Receipt struct {
 	ID     string
 	To     string
 	Status DeliveryState
-	Time   time.Time
-	Err    error
+	// Time when the receipt was received
+	Time time.Time
+	Err  error
+	// ClientID the ClientID the message was sent with, if any
+	ClientID string
+	// RawStatus status exactly as sent by the network e.g. "DELIVRD"
+	RawStatus string
+	// RawDetail detail code exactly as sent by the network, empty if none
+	RawDetail string
+	// NetworkTime when the network reported the status, zero if the receipt
+	// has no timestamp
+	NetworkTime time.Time
+	// Values all values of the receipt request, except the secret token
+	Values url.Values
 }
 
 // ReceiptCallback callback for new delivery receipt
 type ReceiptCallback func(Receipt)
 
+// OverflowPolicy what a ReceiptHandler does with a receipt when its Receipts
+// channel is full
+type OverflowPolicy byte
+
+const (
+	// OverflowBlock wait for room on the channel, the receipt is rejected if
+	// the request is cancelled first
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDrop acknowledge and discard the receipt
+	OverflowDrop
+	// OverflowReject reject the receipt with 503 Service Unavailable, so
+	// Clockwork retries it later
+	OverflowReject
+)
+
 // ReceiptHandler custom handler which processes delivery receipts
 type ReceiptHandler struct {
 	// Path prefixed with "/" i.e. "/delivery-receipts", "/sms"
 	Path string
 	// Port to listen on
 	Port int
-	// Callback called when a new receipt has been received
+	// Addr address of the interface to listen on e.g. "127.0.0.1", empty for
+	// all interfaces
+	Addr string
+	// Callback called when a new receipt has been received. A panic in the
+	// callback is recovered and the receipt rejected, so Clockwork retries.
 	Callback ReceiptCallback
+	// Receipts if set, each new receipt is sent on this channel instead of
+	// to a callback. Exactly one of Callback or Receipts must be set.
+	Receipts chan<- Receipt
+	// Overflow what to do when Receipts is full, defaults to OverflowBlock
+	Overflow OverflowPolicy
+	// Dedup if set, a receipt with the same ID and Status as one already
+	// delivered within DedupWindow is acknowledged without being delivered
+	// again. Receipts are only recorded once delivered, so a rejected receipt
+	// is delivered when Clockwork retries it. Concurrent requests for the same
+	// ID and Status are handled one at a time.
+	Dedup DedupStore
+	// DedupWindow how long receipts are remembered, defaults to
+	// DefaultDedupWindow
+	DedupWindow time.Duration
+	// Journal if set, each receipt is appended to the journal and synced to
+	// disk before it is delivered and acknowledged. A receipt which cannot be
+	// written is rejected, so Clockwork retries it.
+	Journal *ReceiptJournal
+	// MaxBodySize largest POST body accepted in bytes, defaults to
+	// DefaultMaxReceiptBodySize
+	MaxBodySize int64
+	// Secret if set, every request must carry this token either as the
+	// "token" query parameter or as the path segment after Path, e.g.
+	// "/receipts?token=SECRET" or "/receipts/SECRET". Configure the receipt
+	// URL in your Clockwork account to match.
+	Secret string
+	// AllowedNetworks if not empty, only requests from these CIDR ranges
+	// are accepted, e.g. "203.0.113.0/24".
+	AllowedNetworks []string
+	// TrustedProxies CIDR ranges of reverse proxies in front of the handler.
+	// For requests from a trusted proxy the client address is taken from
+	// the X-Forwarded-For header.
+	TrustedProxies []string
+	// ShutdownTimeout longest a graceful shutdown waits for in-flight
+	// receipts, 0 to wait until they finish
+	ShutdownTimeout time.Duration
+	// CertFile and KeyFile if set, receipts are served over TLS using the
+	// PEM encoded certificate and key in these files. The files are reloaded
+	// when they change, so rotated certificates are picked up without a
+	// restart.
+	CertFile string
+	KeyFile  string
+	// TLSConfig if set, receipts are served over TLS using this
+	// configuration. Certificates from CertFile and KeyFile take precedence
+	// over any it holds.
+	TLSConfig *tls.Config
+
+	netsOnce sync.Once
+	allowed  []*net.IPNet
+	proxies  []*net.IPNet
+	netsErr  error
+
+	inflightMu sync.Mutex
+	inflight   map[string]chan struct{}
 }
 
-// ServeHTTP process delivery receipts
+// DefaultMaxReceiptBodySize largest POST body a ReceiptHandler accepts if
+// MaxBodySize is not set
+const DefaultMaxReceiptBodySize = 64 << 10
+
+// ServeHTTP process delivery receipts. Only GET and POST requests are
+// accepted. A receipt which cannot be read or is missing its msg_id or status
+// is rejected with a 4xx status code, so it is not mistaken for an
+// acknowledgement.
+//
+// If Secret, AllowedNetworks or TrustedProxies are set, forged requests are
+// rejected with 403 Forbidden before the body is read.
 func (rc *ReceiptHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	vals := url.Values{}
-	if r.Method == "GET" {
-		vals = r.URL.Query()
+	if code, err := rc.authenticate(r); err != nil {
+		log.Println("serve http:", err)
+		http.Error(w, http.StatusText(code), code)
+		return
 	}
 
-	if r.Method == "POST" {
-		body, err := ioutil.ReadAll(r.Body)
-
-		if err != nil {
-			log.Println(err)
-			return
+	vals, code, err := rc.readValues(r)
+	if err != nil {
+		log.Println("serve http:", err)
+		if code == http.StatusMethodNotAllowed {
+			w.Header().Set("Allow", "GET, POST")
 		}
+		http.Error(w, err.Error(), code)
+		return
+	}
 
-		vals, err = url.ParseQuery(string(body))
-		if err != nil {
-			log.Println(err)
+	if vals.Get("msg_id") == "" || vals.Get("status") == "" {
+		http.Error(w, "msg_id and status are required", http.StatusBadRequest)
+		return
+	}
+
+	receipt := parseReceipt(vals, time.Now().UTC())
+
+	release, err := rc.claim(r.Context(), receipt)
+	if err != nil {
+		log.Println("serve http:", err)
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
+
+	dup, err := rc.duplicate(receipt)
+	if err != nil {
+		log.Println("serve http:", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if dup {
+		// already delivered, acknowledge so Clockwork stops retrying
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if rc.Journal != nil {
+		if err := rc.Journal.append(vals, receipt.Time); err != nil {
+			log.Println("serve http:", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			return
 		}
 	}
 
-	if len(vals) == 0 {
-		log.Println("serve http: query values map is zero")
+	if code, err := rc.deliver(r.Context(), receipt); err != nil {
+		log.Println("serve http:", err)
+		if code == http.StatusServiceUnavailable {
+			w.Header().Set("Retry-After", "60")
+		}
+		http.Error(w, err.Error(), code)
 		return
 	}
+	if err := rc.delivered(receipt); err != nil {
+		log.Println("serve http:", err)
+	}
 
-	var receipt Receipt
-	if v, ok := vals["msg_id"]; ok {
-		receipt.ID = v[0]
+	// need to respond with a 200 OK status code to acknowledge receipt of the
+	// message, otherwise the clockwork API will retry at regular intervals.
+	w.WriteHeader(http.StatusOK)
+}
+
+// parseReceipt builds a receipt from the values of a receipt request received
+// at t
+func parseReceipt(vals url.Values, t time.Time) Receipt {
+	receipt := Receipt{
+		ID:        vals.Get("msg_id"),
+		To:        vals.Get("to"),
+		ClientID:  vals.Get("client_id"),
+		RawStatus: vals.Get("status"),
+		RawDetail: vals.Get("detail"),
+		Time:      t,
+		Values:    make(url.Values, len(vals)),
 	}
+	receipt.Status = toDeliveryState(receipt.RawStatus)
 
-	if v, ok := vals["to"]; ok {
-		receipt.To = v[0]
+	if _, ok := vals["detail"]; ok {
+		receipt.Err = errorFromDetailCode(receipt.RawDetail)
 	}
 
-	if v, ok := vals["status"]; ok {
-		receipt.Status = toDeliveryState(v[0])
+	if v := vals.Get("timestamp"); v != "" {
+		receipt.NetworkTime = parseTimestamp(v)
 	}
 
-	if v, ok := vals["detail"]; ok {
-		receipt.Err = errorFromDetailCode(v[0])
+	for k, v := range vals {
+		if k != "token" {
+			receipt.Values[k] = v
+		}
 	}
+	return receipt
+}
 
-	receipt.Time = time.Now().UTC()
+// parseTimestamp parses a receipt timestamp given in Unix seconds or RFC 3339
+// format, returning the zero time if it is neither
+func parseTimestamp(s string) time.Time {
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC()
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC()
+	}
+	return time.Time{}
+}
 
-	// invoke callback
-	rc.Callback(receipt)
+// deliver passes receipt to the callback or the channel. On failure it
+// returns the HTTP status code to respond with, so Clockwork retries.
+func (rc *ReceiptHandler) deliver(ctx context.Context, receipt Receipt) (code int, err error) {
+	switch {
+	case rc.Callback != nil && rc.Receipts != nil:
+		// a callback followed by a full channel would run the callback
+		// again each time Clockwork retries
+		return http.StatusInternalServerError, errBothCallbackAndChannel
+	case rc.Callback != nil:
+		if err := safeCallback(rc.Callback, receipt); err != nil {
+			return http.StatusInternalServerError, err
+		}
+		return http.StatusOK, nil
+	case rc.Receipts == nil:
+		return http.StatusInternalServerError, errNoCallbackOrChannel
+	}
 
-	// need to respond with a 200 OK status code to acknowledge receipt of the
-	// message, otherwise the clockwork API will retry at regular intervals.
-	w.WriteHeader(http.StatusOK)
+	switch rc.Overflow {
+	case OverflowDrop:
+		select {
+		case rc.Receipts <- receipt:
+		default:
+			log.Println("serve http: receipt channel full, dropped receipt", receipt.ID)
+		}
+	case OverflowReject:
+		select {
+		case rc.Receipts <- receipt:
+		default:
+			return http.StatusServiceUnavailable, errors.New("receipt channel full")
+		}
+	default:
+		select {
+		case rc.Receipts <- receipt:
+		case <-ctx.Done():
+			return http.StatusServiceUnavailable, ctx.Err()
+		}
+	}
+	return http.StatusOK, nil
+}
+
+// ReceiptHandler configuration errors
+var (
+	errNoCallbackOrChannel    = errors.New("callback and receipts channel are both nil")
+	errBothCallbackAndChannel = errors.New("callback and receipts channel are both set")
+)
+
+// safeCallback calls cb, recovering and returning any panic as an error
+func safeCallback(cb ReceiptCallback, receipt Receipt) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("receipt callback panic: %v", p)
+		}
+	}()
+	cb(receipt)
+	return nil
+}
+
+// readValues returns the receipt values from the query string of a GET or the
+// form encoded body of a POST. On failure it returns the HTTP status code to
+// respond with.
+func (rc *ReceiptHandler) readValues(r *http.Request) (url.Values, int, error) {
+	var vals url.Values
+	switch r.Method {
+	case "GET":
+		vals = r.URL.Query()
+	case "POST":
+		limit := rc.MaxBodySize
+		if limit <= 0 {
+			limit = DefaultMaxReceiptBodySize
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, limit+1))
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		if int64(len(body)) > limit {
+			return nil, http.StatusRequestEntityTooLarge, errors.New("request body too large")
+		}
+		vals, err = url.ParseQuery(string(body))
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+	default:
+		return nil, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method)
+	}
+
+	if len(vals) == 0 {
+		return nil, http.StatusBadRequest, errors.New("query values map is zero")
+	}
+	return vals, http.StatusOK, nil
 }
 
 // DeliveryReceiptListen listen for incoming delivery receipts.
 func DeliveryReceiptListen(rh *ReceiptHandler) error {
-	if rh.Callback == nil {
-		return errors.New("callback is nil")
+	return DeliveryReceiptListenContext(context.Background(), rh)
+}
+
+// DeliveryReceiptListenContext listen for incoming delivery receipts until
+// ctx is done, then shut down gracefully. See ReceiptHandler.Serve.
+func DeliveryReceiptListenContext(ctx context.Context, rh *ReceiptHandler) error {
+	if err := rh.validate(); err != nil {
+		return err
 	}
-	if rh.Path == "" {
-		return errors.New("path is empty")
+	l, err := net.Listen("tcp", net.JoinHostPort(rh.Addr, strconv.Itoa(rh.Port)))
+	if err != nil {
+		return err
+	}
+	return rh.Serve(ctx, l)
+}
+
+// Serve serves delivery receipts on l until ctx is done, then shuts down
+// gracefully: l is closed so no new receipts are accepted and Serve waits for
+// in-flight receipts, and their callbacks, to finish, for at most
+// ShutdownTimeout if set. It returns nil after a clean shutdown, otherwise
+// the error which stopped the server.
+//
+// If CertFile and KeyFile or TLSConfig are set, connections on l are served
+// over TLS.
+func (rc *ReceiptHandler) Serve(ctx context.Context, l net.Listener) error {
+	if err := rc.validate(); err != nil {
+		l.Close()
+		return err
+	}
+	tlsConfig, err := rc.tlsConfig()
+	if err != nil {
+		l.Close()
+		return err
+	}
+	if tlsConfig != nil {
+		l = tls.NewListener(l, tlsConfig)
 	}
 	mux := http.NewServeMux()
-	mux.Handle(rh.Path, rh)
-	p := fmt.Sprintf(":%d", rh.Port)
-	return http.ListenAndServe(p, mux)
+	mux.Handle(rc.Path, rc)
+	if rc.Secret != "" && !strings.HasSuffix(rc.Path, "/") {
+		// secret as a path segment
+		mux.Handle(rc.Path+"/", rc)
+	}
+	srv := &http.Server{Handler: mux}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(l)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx := context.Background()
+	if rc.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		shutdownCtx, cancel = context.WithTimeout(shutdownCtx, rc.ShutdownTimeout)
+		defer cancel()
+	}
+	err = srv.Shutdown(shutdownCtx)
+	if serveErr := <-errc; serveErr != http.ErrServerClosed && err == nil {
+		err = serveErr
+	}
+	return err
+}
+
+// validate checks the handler is configured to serve receipts
+func (rc *ReceiptHandler) validate() error {
+	if rc.Callback == nil && rc.Receipts == nil {
+		return errNoCallbackOrChannel
+	}
+	if rc.Callback != nil && rc.Receipts != nil {
+		return errBothCallbackAndChannel
+	}
+	if rc.Path == "" {
+		return errors.New("path is empty")
+	}
+	return rc.parseNetworks()
 }
 
 // toDeliveryState converts clockwork delivery status strings to the
 // DeliveryState type. See:
 // https://www.clockworksms.com/doc/reference/faqs/delivery-states/
 func toDeliveryState(s string) DeliveryState {
-	switch s {
-	case "QUEUED":
-		return Queued
-	case "ENROUTE":
-		return Enroute
-	case "DELIVRD":
-		return Delivered
-	case "EXPIRED":
-		return Expired
-	case "DELETED":
-		return Deleted
-	case "UNDELIV":
-		return Undelivered
-	case "ACCEPTD":
-		return Accepted
-	case "UNKNOWN":
-		return Unknown
-	case "REJECTD":
-		return Rejected
-	default:
-		return 126 // debug
-	}
+	d, _ := ParseDeliveryState(s)
+	return d
 }
 
 // errorFromDetailCode provides more information on why a message has failed.
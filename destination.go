@@ -0,0 +1,68 @@
+package clockwork
+
+import "strings"
+
+// DestinationPolicy restricts which numbers messages may be sent to, by
+// country calling code or any longer number prefix. For example "44" matches
+// every UK number and "449" only UK premium rate numbers. Prefixes may be
+// written with a leading '+' or '00'.
+//
+// The policy is enforced by Clockwork.Send and SendContext before the request
+// is built. DoSendRequestHelper does not apply it.
+//
+//	cw.Destinations = &clockwork.DestinationPolicy{
+//	    Allow: []string{"44", "353"}, // UK and Ireland only
+//	    Deny:  []string{"449"},       // but no UK premium rate numbers
+//	}
+type DestinationPolicy struct {
+	// Allow if not empty, only numbers starting with one of these prefixes
+	// are permitted.
+	Allow []string
+	// Deny numbers starting with one of these prefixes are never permitted.
+	// Deny takes precedence over Allow.
+	Deny []string
+}
+
+// Permitted reports whether the policy allows sending to number. A nil policy
+// permits every number.
+func (p *DestinationPolicy) Permitted(number string) bool {
+	if p == nil {
+		return true
+	}
+	n := normaliseNumber(number)
+	if hasAnyPrefix(n, p.Deny) {
+		return false
+	}
+	return len(p.Allow) == 0 || hasAnyPrefix(n, p.Allow)
+}
+
+// blocked returns the numbers which the policy does not permit
+func (p *DestinationPolicy) blocked(numbers Numbers) Numbers {
+	var nums Numbers
+	for _, n := range numbers {
+		if !p.Permitted(n) {
+			nums = append(nums, n)
+		}
+	}
+	return nums
+}
+
+// checkDestinations returns ErrBlockedDestination and a response flagging the
+// blocked numbers if policy p does not permit every number in to
+func checkDestinations(p *DestinationPolicy, to Numbers) (SMSResponse, error) {
+	if blocked := p.blocked(to); len(blocked) > 0 {
+		return make(SMSResponse).flag("Blocked", blocked), ErrBlockedDestination
+	}
+	return nil, nil
+}
+
+// hasAnyPrefix reports whether number starts with any of prefixes
+func hasAnyPrefix(number string, prefixes []string) bool {
+	for _, p := range prefixes {
+		p = normaliseNumber(p)
+		if p != "" && strings.HasPrefix(number, p) {
+			return true
+		}
+	}
+	return false
+}
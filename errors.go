@@ -1,6 +1,9 @@
 package clockwork
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 // Errors related to delivery receipts. // Check the following link for more
 // information:
@@ -210,10 +213,60 @@ var (
 	// ErrStatusCode Clockwork SMS API returned a non 200 HTTP status code
 	ErrStatusCode = errors.New("clockwork: API request did not return HTTP 200 OK")
 
+	// ErrBlockedDestination one or more numbers are not permitted by the
+	// destination policy, the message was not sent
+	ErrBlockedDestination = errors.New("clockwork: destination blocked by policy - message not sent")
+
+	// ErrSuppressed the number is on the suppression list, the message was not
+	// sent to it
+	ErrSuppressed = errors.New("clockwork: number is on the suppression list - message not sent")
+
+	// ErrBudgetExceeded sending would exceed the daily or monthly budget, the
+	// message was not sent
+	ErrBudgetExceeded = errors.New("clockwork: sending would exceed the budget - message not sent")
+
+	// ErrRateLimited the rate limiter is configured to fail fast and the
+	// request would exceed its rate, the request was not made
+	ErrRateLimited = errors.New("clockwork: rate limit reached - request not made")
+
+	// ErrInvalidTransition a receipt would move a tracked message back to an
+	// earlier delivery state or out of a final one, the receipt was ignored
+	ErrInvalidTransition = errors.New("clockwork: invalid delivery state transition - receipt ignored")
+
 	// ErrUnknown if error is not in errorMap then this error will be returned
 	ErrUnknown = errors.New("clockwork: unknown API error code")
 )
 
+// ParseError the Clockwork SMS API returned a response body which could not be
+// understood.
+type ParseError struct {
+	// Line the offending line of the response body, empty if the body was
+	// empty
+	Line string
+	// Reason why the line could not be parsed
+	Reason string
+}
+
+// Error returns the parse error as a string
+func (e *ParseError) Error() string {
+	if e.Line == "" {
+		return fmt.Sprintf("clockwork: cannot parse API response: %s", e.Reason)
+	}
+	return fmt.Sprintf("clockwork: cannot parse API response line %q: %s", e.Line, e.Reason)
+}
+
+// UnrecognisedStateError a delivery receipt carried a status which is not a
+// known delivery state
+type UnrecognisedStateError struct {
+	// Status the status exactly as sent by the network
+	Status string
+}
+
+// Error returns the unrecognised state error as a string
+func (e *UnrecognisedStateError) Error() string {
+	return fmt.Sprintf("clockwork: unrecognised delivery state %q", e.Status)
+}
+
 // errorMap maps Clockwork API error codes to error messages. The keys (numbers)
 // are important, they match the API error codes documented here:
 // https://www.clockworksms.com/doc/reference/faqs/api-error-codes/
@@ -292,3 +345,20 @@ func errorFromCode(c int) error {
 	}
 	return ErrUnknown
 }
+
+// isAPIError reports whether err is an error code returned by the API, which
+// means the API rejected the request
+func isAPIError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if err == ErrUnknown {
+		return true
+	}
+	for _, e := range errorMap {
+		if e == err {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,306 @@
+package clockwork
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Default mail merge column names
+const (
+	DefaultNumberColumn   = "number"
+	DefaultContentColumn  = "content"
+	DefaultClientIDColumn = "client_id"
+)
+
+// MailMergeOptions configures MailMerge. Column names are matched against
+// the CSV header case insensitively.
+type MailMergeOptions struct {
+	// SMS the message options for every message, its To, Content and
+	// ClientID are set from each row and its IdempotencyKey is cleared
+	SMS SMS
+	// Template if set, the content of each message is rendered from this
+	// text/template with the row's columns as data, e.g. "Hi {{.name}}".
+	// Otherwise the content column is sent as is.
+	Template string
+	// NumberColumn name of the column holding the number, defaults to
+	// DefaultNumberColumn
+	NumberColumn string
+	// ContentColumn name of the column holding the content, defaults to
+	// DefaultContentColumn
+	ContentColumn string
+	// ClientIDColumn name of the optional column holding the ClientID,
+	// defaults to DefaultClientIDColumn
+	ClientIDColumn string
+}
+
+// MailMergeSummary counts the rows processed by MailMerge
+type MailMergeSummary struct {
+	// Rows number of rows read, excluding the header
+	Rows int
+	// Sent number of rows given a message ID
+	Sent int
+	// Failed number of rows with an error
+	Failed int
+}
+
+// mergeRow a row of a mail merge and its outcome
+type mergeRow struct {
+	record    []string
+	number    string
+	clientID  string
+	content   string
+	messageID string
+	err       error
+}
+
+// MailMerge sends a message for each row of the CSV read from r and writes
+// the outcome of each row as CSV to w.
+//
+// The first row of the input is a header. Numbers are normalised (spaces,
+// punctuation and any leading '+' or '00' removed). Rows with the same content
+// and no ClientID are sent together, up to 50 numbers per SMS; rows with a
+// ClientID are sent alone as a ClientID identifies a single message.
+//
+// The output has the input's columns, with numbers normalised, followed by
+// "message_id" and "error" columns. Per row failures are reported in the
+// output rather than returned. Rows are written in input order as soon as
+// their batch has been sent, so if the run is interrupted the output records
+// every row already sent. An error is returned if the input cannot be read,
+// the output cannot be written or ctx is done; sending stops if the output
+// cannot be written.
+func MailMerge(ctx context.Context, s Sender, r io.Reader, w io.Writer, opts MailMergeOptions) (MailMergeSummary, error) {
+	var summary MailMergeSummary
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err == io.EOF {
+		return summary, fmt.Errorf("clockwork: mail merge: no header row")
+	}
+	if err != nil {
+		return summary, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	column := func(name, def string) (int, bool) {
+		if name == "" {
+			name = def
+		}
+		i, ok := columns[strings.ToLower(name)]
+		return i, ok
+	}
+	numberCol, ok := column(opts.NumberColumn, DefaultNumberColumn)
+	if !ok {
+		return summary, fmt.Errorf("clockwork: mail merge: no number column")
+	}
+	contentCol, hasContent := column(opts.ContentColumn, DefaultContentColumn)
+	clientIDCol, hasClientID := column(opts.ClientIDColumn, DefaultClientIDColumn)
+
+	var tmpl *Template
+	if opts.Template != "" {
+		if tmpl, err = NewTemplate(opts.SMS, opts.Template); err != nil {
+			return summary, err
+		}
+	} else if !hasContent {
+		return summary, fmt.Errorf("clockwork: mail merge: no content column or template")
+	}
+
+	var rows []*mergeRow
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return summary, err
+		}
+		row := &mergeRow{record: record}
+		rows = append(rows, row)
+
+		field := func(i int) string {
+			if i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		row.number = normaliseNumber(field(numberCol))
+		if numberCol < len(record) {
+			record[numberCol] = row.number
+		}
+		if hasClientID {
+			row.clientID = field(clientIDCol)
+		}
+		if !validNumber(row.number) {
+			row.err = ErrInvalidTo
+			continue
+		}
+		if tmpl != nil {
+			data := make(map[string]interface{}, len(header))
+			for i, name := range header {
+				data[strings.TrimSpace(name)] = field(i)
+			}
+			row.content, row.err = tmpl.render(Recipient{Number: row.number, Data: data})
+			continue
+		}
+		sms := opts.SMS
+		sms.Content = field(contentCol)
+		row.content, row.err = sms.Content, validateContent(sms)
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append(header, "message_id", "error")); err != nil {
+		return summary, err
+	}
+	// write rows in input order as soon as they are finished, so the output
+	// records which rows were sent even if the run is killed
+	var next int
+	flush := func() error {
+		for ; next < len(rows) && rows[next].finished(); next++ {
+			row := rows[next]
+			summary.Rows++
+			var errText string
+			if row.err != nil {
+				summary.Failed++
+				errText = row.err.Error()
+			} else {
+				summary.Sent++
+			}
+			if err := cw.Write(append(row.record, row.messageID, errText)); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	if err := flush(); err != nil {
+		return summary, err
+	}
+
+	sendErr := sendMergeBatches(ctx, s, opts.SMS, rows, flush)
+	if err := flush(); err != nil {
+		return summary, err
+	}
+	return summary, sendErr
+}
+
+// finished reports whether the row has failed or been sent
+func (row *mergeRow) finished() bool {
+	return row.err != nil || row.messageID != ""
+}
+
+// sendMergeBatches sends the rows without errors, grouping rows with the same
+// content, and records the outcome on each row. It calls flush after each
+// batch, stopping if flush fails. It returns ctx.Err() if ctx is done before
+// every batch is sent.
+func sendMergeBatches(ctx context.Context, s Sender, base SMS, rows []*mergeRow, flush func() error) error {
+	type batch struct {
+		sms  SMS
+		rows []*mergeRow
+	}
+	var batches []*batch
+	open := make(map[string]*batch)
+	for _, row := range rows {
+		if row.err != nil {
+			continue
+		}
+		b := open[row.content]
+		if row.clientID != "" || b == nil || len(b.rows) >= maxRecipients {
+			sms := base
+			sms.To = nil
+			sms.Content = row.content
+			sms.ClientID = row.clientID
+			// batches have different content, so cannot share a key
+			sms.IdempotencyKey = ""
+			b = &batch{sms: sms}
+			batches = append(batches, b)
+			if row.clientID == "" {
+				open[row.content] = b
+			}
+		}
+		b.sms.To = append(b.sms.To, row.number)
+		b.rows = append(b.rows, row)
+	}
+
+	for i, b := range batches {
+		if err := ctx.Err(); err != nil {
+			for _, rest := range batches[i:] {
+				for _, row := range rest.rows {
+					row.err = err
+				}
+			}
+			return err
+		}
+		resp, err := s.SendContext(ctx, b.sms)
+		if err == ErrBlockedDestination {
+			// one blocked number stops the whole batch, so send again
+			// without the blocked rows
+			b.rows, b.sms.To = dropBlocked(b.rows, resp)
+			if len(b.rows) == 0 {
+				if err := flush(); err != nil {
+					return err
+				}
+				continue
+			}
+			resp, err = s.SendContext(ctx, b.sms)
+		}
+		for _, row := range b.rows {
+			if row.err != nil {
+				continue
+			}
+			meta := resp[row.number]
+			switch {
+			case meta["ID"] != "":
+				row.messageID = meta["ID"]
+			case meta["Suppressed"] == "true":
+				row.err = ErrSuppressed
+			case err != nil:
+				row.err = err
+			default:
+				row.err = ErrInvalidTo
+			}
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropBlocked marks the rows whose numbers resp lists as blocked with
+// ErrBlockedDestination and returns the remaining rows and their numbers
+func dropBlocked(rows []*mergeRow, resp SMSResponse) ([]*mergeRow, Numbers) {
+	blocked := make(map[string]bool)
+	for _, n := range resp.Blocked() {
+		blocked[n] = true
+	}
+	var keep []*mergeRow
+	var to Numbers
+	for _, row := range rows {
+		if blocked[row.number] || len(blocked) == 0 {
+			row.err = ErrBlockedDestination
+			continue
+		}
+		keep = append(keep, row)
+		to = append(to, row.number)
+	}
+	return keep, to
+}
+
+// validNumber reports whether a normalised number looks like an international
+// number, 7 to 15 digits
+func validNumber(n string) bool {
+	if len(n) < 7 || len(n) > 15 {
+		return false
+	}
+	for _, r := range n {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,179 @@
+package clockwork
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BalanceChecker implemented by any type which can check an account balance,
+// such as *Clockwork. The check should stop when ctx is done.
+type BalanceChecker interface {
+	BalanceContext(ctx context.Context) (Balance, error)
+}
+
+// CreditLevel how healthy an account balance is
+type CreditLevel byte
+
+const (
+	// CreditOK balance is above the warning threshold
+	CreditOK CreditLevel = iota
+	// CreditWarning balance is at or below the warning threshold
+	CreditWarning
+	// CreditCritical balance is at or below the critical threshold
+	CreditCritical
+)
+
+// String returns credit level as a string
+func (l CreditLevel) String() string {
+	switch l {
+	case CreditOK:
+		return "OK"
+	case CreditWarning:
+		return "Warning"
+	case CreditCritical:
+		return "Critical"
+	default:
+		return "!ERROR!" // debug
+	}
+}
+
+// DefaultMonitorInterval how often a CreditMonitor checks the balance if no
+// interval is set
+const DefaultMonitorInterval = 5 * time.Minute
+
+// CreditMonitor polls the account balance and calls back when it crosses a
+// threshold, so running out of credit is noticed before sends start failing
+// with ErrInsufficientCredit. Callbacks fire once per change, not on every
+// poll.
+//
+//	m := &clockwork.CreditMonitor{
+//	    Checker:    cw,
+//	    Warn:       5000, // 50.00 GBP
+//	    Critical:   1000, // 10.00 GBP
+//	    OnWarn:     func(b clockwork.Balance) { log.Println("credit low", b) },
+//	    OnCritical: func(b clockwork.Balance) { log.Println("credit critical", b) },
+//	}
+//	go m.Run(ctx)
+type CreditMonitor struct {
+	// Checker checks the balance, usually a *Clockwork
+	Checker BalanceChecker
+	// Interval between balance checks, defaults to DefaultMonitorInterval
+	Interval time.Duration
+	// Warn balance in minor units at or below which the level is
+	// CreditWarning
+	Warn int64
+	// Critical balance in minor units at or below which the level is
+	// CreditCritical. Should be lower than Warn.
+	Critical int64
+	// OnWarn called when the balance falls to the warning level
+	OnWarn func(Balance)
+	// OnCritical called when the balance falls to the critical level
+	OnCritical func(Balance)
+	// OnRecover called when the balance rises back above the warning level
+	OnRecover func(Balance)
+	// OnError called when checking the balance starts failing. It is not
+	// called again until a check has succeeded.
+	OnError func(error)
+
+	mu        sync.Mutex
+	last      Balance
+	lastCheck time.Time
+	lastErr   error
+	level     CreditLevel
+	checked   bool
+	failing   bool
+}
+
+// Run checks the balance immediately and then every Interval until ctx is
+// done. It returns ctx.Err().
+func (m *CreditMonitor) Run(ctx context.Context) error {
+	interval := m.Interval
+	if interval <= 0 {
+		interval = DefaultMonitorInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.CheckContext(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check checks the balance once, updating the cached balance and firing any
+// callbacks.
+func (m *CreditMonitor) Check() (Balance, error) {
+	return m.CheckContext(context.Background())
+}
+
+// CheckContext is like Check but the balance check is cancelled when ctx is
+// done. A balance with more decimal places than its currency is rounded down
+// to the currency's minor unit, rather than failing every check.
+func (m *CreditMonitor) CheckContext(ctx context.Context) (Balance, error) {
+	b, err := m.Checker.BalanceContext(ctx)
+	if rounded, ok := roundedBalance(err); ok {
+		b, err = rounded, nil
+	}
+
+	m.mu.Lock()
+	m.lastCheck = time.Now().UTC()
+	m.lastErr = err
+	if err != nil {
+		startedFailing := !m.failing
+		m.failing = true
+		m.mu.Unlock()
+		if startedFailing && m.OnError != nil {
+			m.OnError(err)
+		}
+		return b, err
+	}
+	prev, first := m.level, !m.checked
+	m.last, m.level, m.checked, m.failing = b, m.levelOf(b), true, false
+	level := m.level
+	m.mu.Unlock()
+
+	if level == prev && !first {
+		return b, nil
+	}
+	switch {
+	case level == CreditCritical && m.OnCritical != nil:
+		m.OnCritical(b)
+	case level == CreditWarning && prev != CreditCritical && m.OnWarn != nil:
+		m.OnWarn(b)
+	case level == CreditOK && !first && m.OnRecover != nil:
+		m.OnRecover(b)
+	}
+	return b, nil
+}
+
+// Last returns the most recently retrieved balance, when the last check was
+// made and its error if it failed. The balance is kept from the last
+// successful check.
+func (m *CreditMonitor) Last() (Balance, time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last, m.lastCheck, m.lastErr
+}
+
+// Level returns the credit level from the last successful check
+func (m *CreditMonitor) Level() CreditLevel {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.level
+}
+
+// levelOf returns the credit level of b
+func (m *CreditMonitor) levelOf(b Balance) CreditLevel {
+	switch {
+	case b.Amount <= m.Critical:
+		return CreditCritical
+	case b.Amount <= m.Warn:
+		return CreditWarning
+	default:
+		return CreditOK
+	}
+}
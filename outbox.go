@@ -0,0 +1,377 @@
+package clockwork
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OutboxState state of a message held in an Outbox
+type OutboxState string
+
+const (
+	// OutboxPending waiting to be sent or retried
+	OutboxPending OutboxState = "pending"
+	// OutboxSent accepted by the API
+	OutboxSent OutboxState = "sent"
+	// OutboxFailed failed permanently, it will not be retried
+	OutboxFailed OutboxState = "failed"
+)
+
+// OutboxEntry a message held in an Outbox
+type OutboxEntry struct {
+	// ID identifies the entry in the store
+	ID string
+	// SMS the message to send. Its ClientID is always set.
+	SMS SMS
+	// State of the entry
+	State OutboxState
+	// Attempts number of times sending has been tried
+	Attempts int
+	// LastError error from the last attempt, empty if it succeeded
+	LastError string
+	// Created when the entry was added
+	Created time.Time
+	// NextAttempt a pending entry is not retried before this time
+	NextAttempt time.Time
+	// Sent when the API accepted the message
+	Sent time.Time
+	// Response from the API, holding the message IDs
+	Response SMSResponse
+}
+
+// OutboxStore persists outbox entries so they survive a restart
+type OutboxStore interface {
+	// Put adds or replaces the entry with e.ID
+	Put(e OutboxEntry) error
+	// Get returns the entry with id
+	Get(id string) (OutboxEntry, error)
+	// Pending returns the entries in OutboxPending state, oldest first
+	Pending() ([]OutboxEntry, error)
+}
+
+// ErrOutboxEntryNotFound no outbox entry exists with the given id
+var ErrOutboxEntryNotFound = errors.New("clockwork: outbox entry not found")
+
+// FileOutboxStore stores each outbox entry as a JSON file in a directory.
+// Files are written to a temporary file, synced and renamed into place, so an
+// entry is never half written. Sent and failed entries are moved to a "done"
+// subdirectory, so Pending only reads the entries still waiting to be sent.
+type FileOutboxStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileOutboxStore creates a store in dir, creating the directory if needed
+func NewFileOutboxStore(dir string) (*FileOutboxStore, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "done"), 0700); err != nil {
+		return nil, err
+	}
+	return &FileOutboxStore{dir: dir}, nil
+}
+
+// Put adds or replaces the entry with e.ID
+func (s *FileOutboxStore) Put(e OutboxEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e.State == OutboxPending {
+		return writeFileSync(s.path(e.ID), data)
+	}
+	// write the completed entry before removing the pending one, a crash in
+	// between at worst sends the message again
+	if err := writeFileSync(s.donePath(e.ID), data); err != nil {
+		return err
+	}
+	if err := os.Remove(s.path(e.ID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Get returns the entry with id
+func (s *FileOutboxStore) Get(id string) (OutboxEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.read(s.donePath(id))
+	if err == ErrOutboxEntryNotFound {
+		return s.read(s.path(id))
+	}
+	return e, err
+}
+
+// Delete removes the entry with id, for example once a sent entry is no
+// longer needed
+func (s *FileOutboxStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range []string{s.path(id), s.donePath(id)} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Pending returns the entries in OutboxPending state, oldest first
+func (s *FileOutboxStore) Pending() ([]OutboxEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var pending []OutboxEntry
+	for _, p := range paths {
+		e, err := s.read(p)
+		if err != nil {
+			return nil, err
+		}
+		if e.State == OutboxPending {
+			pending = append(pending, e)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Created.Before(pending[j].Created)
+	})
+	return pending, nil
+}
+
+// path returns the file holding the entry with id. Entry ids are generated
+// hex strings, anything else is hex encoded to keep it a safe file name.
+func (s *FileOutboxStore) path(id string) string {
+	if strings.Trim(id, "0123456789abcdef") != "" {
+		id = hex.EncodeToString([]byte(id))
+	}
+	return filepath.Join(s.dir, id+".json")
+}
+
+// donePath returns the file holding the sent or failed entry with id
+func (s *FileOutboxStore) donePath(id string) string {
+	return filepath.Join(s.dir, "done", filepath.Base(s.path(id)))
+}
+
+// read decodes the entry stored at path
+func (s *FileOutboxStore) read(path string) (OutboxEntry, error) {
+	var e OutboxEntry
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return e, ErrOutboxEntryNotFound
+	}
+	if err != nil {
+		return e, err
+	}
+	err = json.Unmarshal(data, &e)
+	return e, err
+}
+
+// writeFileSync atomically replaces the file at path with data, syncing it to
+// disk before returning
+func writeFileSync(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Outbox defaults
+const (
+	// DefaultOutboxMaxAttempts attempts before an entry is marked failed if
+	// MaxAttempts is not set
+	DefaultOutboxMaxAttempts = 10
+	// DefaultOutboxRetryDelay delay before the first retry if RetryDelay is
+	// not set. The delay doubles with each attempt.
+	DefaultOutboxRetryDelay = 30 * time.Second
+	// maxOutboxRetryDelay longest delay between retries
+	maxOutboxRetryDelay = time.Hour
+)
+
+// Outbox persists messages before they are sent and drains them through a
+// Sender with retries, so a message is not lost if the process dies between
+// deciding to send it and sending it. Delivery is at-least-once: every entry
+// has a ClientID and UniqueIDChecks enabled, so if an entry is replayed after
+// it was sent the API rejects the duplicate (ErrDuplicateClientID) and the
+// entry is marked sent.
+//
+//	store, err := clockwork.NewFileOutboxStore("/var/lib/app/outbox")
+//	// ...
+//	ob := &clockwork.Outbox{Store: store, Sender: cw}
+//	go ob.Run(ctx, time.Minute)
+//
+//	id, err := ob.Enqueue(msg)
+type Outbox struct {
+	// Store persists the entries
+	Store OutboxStore
+	// Sender sends the entries, usually a *Clockwork
+	Sender Sender
+	// MaxAttempts attempts before an entry is marked failed, defaults to
+	// DefaultOutboxMaxAttempts
+	MaxAttempts int
+	// RetryDelay delay before the first retry, defaults to
+	// DefaultOutboxRetryDelay
+	RetryDelay time.Duration
+
+	mu sync.Mutex
+}
+
+// Enqueue persists sms to be sent by the next Drain and returns its entry id.
+// If sms has no ClientID one is generated, from its IdempotencyKey if set,
+// and UniqueIDChecks is enabled.
+func (o *Outbox) Enqueue(sms SMS) (string, error) {
+	id, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	if sms.ClientID == "" {
+		if sms.ClientID, err = generateClientID(sms.IdempotencyKey); err != nil {
+			return "", err
+		}
+	}
+	if len(sms.ClientID) > maxClientIDLength {
+		return "", ErrLongClientID
+	}
+	sms.UniqueIDChecks = true
+	now := time.Now().UTC()
+	err = o.Store.Put(OutboxEntry{
+		ID:          id,
+		SMS:         sms,
+		State:       OutboxPending,
+		Created:     now,
+		NextAttempt: now,
+	})
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Drain tries to send every pending entry which is due. Entries which fail
+// with a temporary error are retried by a later Drain. It stops early and
+// returns an error if the store fails or ctx is done.
+func (o *Outbox) Drain(ctx context.Context) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	pending, err := o.Store.Pending()
+	if err != nil {
+		return err
+	}
+	for _, e := range pending {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if time.Now().Before(e.NextAttempt) {
+			continue
+		}
+		if err := o.Store.Put(o.attempt(ctx, e)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run drains the outbox immediately and then every interval until ctx is
+// done. It returns ctx.Err(), or the first store error.
+func (o *Outbox) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := o.Drain(ctx); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// attempt sends e once and returns it updated with the outcome
+func (o *Outbox) attempt(ctx context.Context, e OutboxEntry) OutboxEntry {
+	resp, err := o.Sender.SendContext(ctx, e.SMS)
+	e.Attempts++
+	e.LastError = ""
+	if err != nil {
+		e.LastError = err.Error()
+	}
+	switch {
+	case err == nil, err == ErrInvalidTo && len(resp) > 0:
+		e.State, e.Sent, e.Response = OutboxSent, time.Now().UTC(), resp
+	case err == ErrDuplicateClientID:
+		// sent by an earlier attempt whose outcome was not recorded
+		e.State, e.Sent = OutboxSent, time.Now().UTC()
+	case !temporary(err) || e.Attempts >= o.maxAttempts():
+		e.State = OutboxFailed
+	default:
+		e.NextAttempt = time.Now().UTC().Add(o.retryDelay(e.Attempts))
+	}
+	return e
+}
+
+// maxAttempts returns MaxAttempts or its default
+func (o *Outbox) maxAttempts() int {
+	if o.MaxAttempts > 0 {
+		return o.MaxAttempts
+	}
+	return DefaultOutboxMaxAttempts
+}
+
+// retryDelay returns the delay before retrying after attempts
+func (o *Outbox) retryDelay(attempts int) time.Duration {
+	d := o.RetryDelay
+	if d <= 0 {
+		d = DefaultOutboxRetryDelay
+	}
+	for i := 1; i < attempts && d < maxOutboxRetryDelay; i++ {
+		d *= 2
+	}
+	if d > maxOutboxRetryDelay {
+		d = maxOutboxRetryDelay
+	}
+	return d
+}
+
+// temporary reports whether a send which failed with err may succeed if
+// retried. Errors the API returns about the message itself are permanent,
+// anything else (network errors, throttling, lack of credit) is temporary.
+func temporary(err error) bool {
+	switch err {
+	case ErrInternal, ErrInsufficientCredit, ErrRateExceeded, ErrStatusCode,
+		ErrRateLimited, ErrBudgetExceeded:
+		return true
+	case ErrBlockedDestination:
+		return false
+	}
+	for _, apiErr := range errorMap {
+		if err == apiErr {
+			return false
+		}
+	}
+	return true
+}
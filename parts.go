@@ -0,0 +1,91 @@
+package clockwork
+
+import "unicode/utf16"
+
+// gsmBasic characters in the GSM 03.38 basic character set, each encoded in
+// one septet.
+// https://www.clockworksms.com/doc/reference/faqs/gsm-character-set/
+const gsmBasic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// gsmExtended characters in the GSM 03.38 extension table, each encoded in two
+// septets (escape + character).
+const gsmExtended = "\f^{}\\[~]|€"
+
+var (
+	gsmBasicSet    = runeSet(gsmBasic)
+	gsmExtendedSet = runeSet(gsmExtended)
+)
+
+// runeSet returns the set of runes in s
+func runeSet(s string) map[rune]bool {
+	m := make(map[rune]bool)
+	for _, r := range s {
+		m[r] = true
+	}
+	return m
+}
+
+// Message part sizes, a message longer than a single part is split into
+// parts which each carry a header, leaving less room for content.
+const (
+	gsmSinglePart  = 160
+	gsmMultiPart   = 153
+	ucs2SinglePart = 70
+	ucs2MultiPart  = 67
+)
+
+// isGSM reports whether every character in s is in the GSM character set
+func isGSM(s string) bool {
+	for _, r := range s {
+		if !gsmBasicSet[r] && !gsmExtendedSet[r] {
+			return false
+		}
+	}
+	return true
+}
+
+// gsmLength returns the number of septets needed to encode s in the GSM
+// character set. Characters outside the set are counted as one, as though
+// replaced.
+func gsmLength(s string) int {
+	n := 0
+	for _, r := range s {
+		n++
+		if gsmExtendedSet[r] {
+			n++
+		}
+	}
+	return n
+}
+
+// Parts estimates the number of SMS parts needed to deliver the message to a
+// single number, i.e. the number of messages each recipient is billed for.
+// TEXT messages are counted in GSM characters, UCS2 messages in UTF-16 code
+// units. If Truncate is ReplaceExtraText the count is capped at Concat.
+func (s SMS) Parts() int {
+	var length, single, multi int
+	if s.MsgType == UCS2 {
+		length = len(utf16.Encode([]rune(s.Content)))
+		single, multi = ucs2SinglePart, ucs2MultiPart
+	} else {
+		length = gsmLength(s.Content)
+		single, multi = gsmSinglePart, gsmMultiPart
+	}
+
+	parts := 1
+	if length > single {
+		parts = (length + multi - 1) / multi
+	}
+
+	if s.Truncate == ReplaceExtraText {
+		concat := s.Concat
+		if concat < OnePart {
+			concat = OnePart
+		}
+		if parts > concat {
+			parts = concat
+		}
+	}
+	return parts
+}
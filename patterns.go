@@ -1,9 +1,21 @@
 package clockwork
 
-const (
-	matchErrorNumber  = "([0-9])\\w+"
-	matchTo           = "(To: [0-9])\\w+"
-	matchID           = "(ID: [A-Z0-9])\\w+"
-	matchCurrency     = "[-+]?([0-9]*\\.[0-9]+|[0-9]+)"
-	matchCurrencyCode = "([A-Z]{2})\\w+"
+import "regexp"
+
+// Patterns matching lines of the plain text API responses. Lines are trimmed
+// and runs of whitespace collapsed to a single space before matching.
+var (
+	// Error 58: Invalid API Key
+	matchError = regexp.MustCompile(`^Error ([0-9]+):? ?(.*)$`)
+	// To: 123 Error 10: Invalid 'To' Parameter
+	matchToError = regexp.MustCompile(`^To: ([^ ]+) Error ([0-9]+):? ?(.*)$`)
+	// To: 441234567890 ID: VE_439221450 [<key>: <value> ...]
+	matchSendLine = regexp.MustCompile(`^To: ([^ ]+) ID: ([^ ]+)(?: (.*))?$`)
+	// the ClientID key, whose value runs to the end of the line
+	matchClientIDKey = regexp.MustCompile(`(?:^| )ClientID:(?: |$)`)
+	// the keys of a line of key/value fields e.g.
+	// To: 441234567890 ID: VE_439221450 ClientID: ref-42
+	matchFieldKey = regexp.MustCompile(`(?:^| )([A-Za-z][A-Za-z0-9_]*):(?: |$)`)
+	// Balance: 287.58 (GBP)
+	matchBalance = regexp.MustCompile(`^Balance: ([-+]?(?:[0-9]*\.[0-9]+|[0-9]+)) \(([A-Z]{3})\)$`)
 )
@@ -0,0 +1,142 @@
+package clockwork
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimit token bucket rates. Each bucket holds up to one second's worth of
+// tokens (at least one), so short bursts up to the rate are sent immediately.
+// A request larger than the burst, such as one SMS to more numbers than the
+// Messages rate, is allowed once the bucket is full and the requests after it
+// wait for the excess to be repaid. A rate of zero is unlimited.
+type RateLimit struct {
+	// Messages per second. A message is one SMS to one number, for status
+	// requests it is one request.
+	Messages float64
+	// Parts per second, see SMS.Parts. Ignored for status requests.
+	Parts float64
+}
+
+// RateLimiter limits the rate of requests made by Clockwork, keeping clear of
+// the API's own throttling (ErrRateExceeded). Send and status requests (such
+// as balance checks) are limited separately. It is safe for concurrent use.
+//
+//	cw.Limiter = &clockwork.RateLimiter{
+//	    Send:   clockwork.RateLimit{Messages: 10, Parts: 20},
+//	    Status: clockwork.RateLimit{Messages: 1},
+//	}
+type RateLimiter struct {
+	// Send limits for sending messages
+	Send RateLimit
+	// Status limits for status requests
+	Status RateLimit
+	// FailFast if true, requests over the rate return ErrRateLimited
+	// immediately rather than waiting.
+	FailFast bool
+
+	mu             sync.Mutex
+	sendMessages   bucket
+	sendParts      bucket
+	statusMessages bucket
+}
+
+// waitSend waits until messages and parts may be sent
+func (l *RateLimiter) waitSend(ctx context.Context, messages, parts int) error {
+	if l == nil {
+		return nil
+	}
+	return l.wait(ctx, []take{
+		{&l.sendMessages, l.Send.Messages, float64(messages)},
+		{&l.sendParts, l.Send.Parts, float64(parts)},
+	})
+}
+
+// waitStatus waits until a status request may be made
+func (l *RateLimiter) waitStatus(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	return l.wait(ctx, []take{
+		{&l.statusMessages, l.Status.Messages, 1},
+	})
+}
+
+// take n tokens from bucket b which refills at rate
+type take struct {
+	b    *bucket
+	rate float64
+	n    float64
+}
+
+// wait takes tokens from each bucket, waiting until they are all available.
+// If the wait is abandoned the tokens are put back.
+func (l *RateLimiter) wait(ctx context.Context, takes []take) error {
+	l.mu.Lock()
+	now := time.Now()
+	var delay time.Duration
+	for _, t := range takes {
+		if d := t.b.take(now, t.rate, t.n); d > delay {
+			delay = d
+		}
+	}
+	giveBack := func() {
+		for _, t := range takes {
+			t.b.tokens += t.n
+		}
+	}
+	if delay > 0 && l.FailFast {
+		giveBack()
+		l.mu.Unlock()
+		return ErrRateLimited
+	}
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		giveBack()
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// bucket token bucket refilled at a rate of tokens per second, holding up to
+// one second's worth
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take removes n tokens from the bucket, which may leave it in debt, and
+// returns how long until the debt is repaid. Only the part of n up to the
+// burst must be repaid before the request may go ahead, so a request larger
+// than the burst is not refused forever. A rate of zero is unlimited.
+func (b *bucket) take(now time.Time, rate float64, n float64) time.Duration {
+	if rate <= 0 {
+		b.tokens, b.last = 0, time.Time{}
+		return 0
+	}
+	burst := math.Max(1, rate)
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	}
+	b.last = now
+	b.tokens -= n
+	excess := math.Max(0, n-burst)
+	if b.tokens >= -excess {
+		return 0
+	}
+	return time.Duration((-excess - b.tokens) / rate * float64(time.Second))
+}
@@ -0,0 +1,108 @@
+package clockwork
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// authenticate checks the request carries the secret and comes from an
+// allowed network. On failure it returns the HTTP status code to respond with.
+func (rc *ReceiptHandler) authenticate(r *http.Request) (int, error) {
+	if err := rc.parseNetworks(); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if len(rc.allowed) > 0 {
+		ip := rc.clientIP(r)
+		if ip == nil || !containsIP(rc.allowed, ip) {
+			return http.StatusForbidden, fmt.Errorf("receipt from %s not in allowed networks", r.RemoteAddr)
+		}
+	}
+
+	if rc.Secret != "" {
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			token = strings.TrimPrefix(r.URL.Path, strings.TrimSuffix(rc.Path, "/")+"/")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(rc.Secret)) != 1 {
+			return http.StatusForbidden, fmt.Errorf("receipt from %s has a bad token", r.RemoteAddr)
+		}
+	}
+	return http.StatusOK, nil
+}
+
+// parseNetworks parses AllowedNetworks and TrustedProxies the first time it
+// is called
+func (rc *ReceiptHandler) parseNetworks() error {
+	rc.netsOnce.Do(func() {
+		if rc.allowed, rc.netsErr = parseCIDRs(rc.AllowedNetworks); rc.netsErr != nil {
+			return
+		}
+		rc.proxies, rc.netsErr = parseCIDRs(rc.TrustedProxies)
+	})
+	return rc.netsErr
+}
+
+// clientIP returns the address of the client which made r. If the request
+// came through trusted proxies the X-Forwarded-For header is walked from the
+// right, skipping trusted proxies, to find the first untrusted address.
+func (rc *ReceiptHandler) clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !containsIP(rc.proxies, ip) {
+		return ip
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			return nil
+		}
+		ip = hop
+		if !containsIP(rc.proxies, hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// parseCIDRs parses each CIDR range, a bare address is treated as a single
+// host range
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, c := range cidrs {
+		if !strings.Contains(c, "/") {
+			if ip := net.ParseIP(c); ip != nil {
+				bits := 8 * len(ip.To16())
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 32
+				}
+				nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		}
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, errors.New("clockwork: invalid network " + c)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// containsIP reports whether any of nets contains ip
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
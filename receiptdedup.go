@@ -0,0 +1,136 @@
+package clockwork
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultDedupWindow how long a ReceiptHandler remembers a receipt for
+// deduplication if DedupWindow is not set
+const DefaultDedupWindow = 24 * time.Hour
+
+// DedupStore remembers which receipts a ReceiptHandler has already delivered.
+// Keys identify a message and delivery state, so each state transition is
+// delivered once.
+type DedupStore interface {
+	// Seen reports whether key was added at or after since
+	Seen(key string, since time.Time) (bool, error)
+	// Add records key as delivered at t
+	Add(key string, t time.Time) error
+}
+
+// MemoryDedupStore dedup store held in memory. Entries older than the window
+// last passed to Seen are pruned as new keys are added. It is safe for
+// concurrent use.
+type MemoryDedupStore struct {
+	mu     sync.Mutex
+	keys   map[string]time.Time
+	cutoff time.Time
+	pruned time.Time
+}
+
+// NewMemoryDedupStore creates an empty dedup store
+func NewMemoryDedupStore() *MemoryDedupStore {
+	return &MemoryDedupStore{keys: make(map[string]time.Time)}
+}
+
+// Seen reports whether key was added at or after since
+func (s *MemoryDedupStore) Seen(key string, since time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if since.After(s.cutoff) {
+		s.cutoff = since
+	}
+	t, ok := s.keys[key]
+	return ok && !t.Before(since), nil
+}
+
+// Add records key as delivered at t
+func (s *MemoryDedupStore) Add(key string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key] = t
+
+	// prune at most once a minute so a busy handler is not scanning the
+	// whole map on every receipt
+	if t.Sub(s.pruned) < time.Minute {
+		return nil
+	}
+	for k, added := range s.keys {
+		if added.Before(s.cutoff) {
+			delete(s.keys, k)
+		}
+	}
+	s.pruned = t
+	return nil
+}
+
+// Len returns the number of keys held
+func (s *MemoryDedupStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.keys)
+}
+
+// dedupKey identifies a receipt's state transition
+func dedupKey(receipt Receipt) string {
+	return receipt.ID + "\x00" + receipt.RawStatus
+}
+
+// claim waits until no other request is handling a receipt with the same ID
+// and Status, so a concurrent retry sees the first one's delivery when it
+// checks for a duplicate. The returned func releases the claim. Receipts are
+// only serialised when Dedup is set.
+func (rc *ReceiptHandler) claim(ctx context.Context, receipt Receipt) (func(), error) {
+	if rc.Dedup == nil {
+		return func() {}, nil
+	}
+	key := dedupKey(receipt)
+	for {
+		rc.inflightMu.Lock()
+		if rc.inflight == nil {
+			rc.inflight = make(map[string]chan struct{})
+		}
+		busy, ok := rc.inflight[key]
+		if !ok {
+			done := make(chan struct{})
+			rc.inflight[key] = done
+			rc.inflightMu.Unlock()
+			return func() {
+				rc.inflightMu.Lock()
+				delete(rc.inflight, key)
+				rc.inflightMu.Unlock()
+				close(done)
+			}, nil
+		}
+		rc.inflightMu.Unlock()
+
+		select {
+		case <-busy:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// duplicate reports whether receipt was already delivered within the dedup
+// window
+func (rc *ReceiptHandler) duplicate(receipt Receipt) (bool, error) {
+	if rc.Dedup == nil {
+		return false, nil
+	}
+	window := rc.DedupWindow
+	if window <= 0 {
+		window = DefaultDedupWindow
+	}
+	return rc.Dedup.Seen(dedupKey(receipt), receipt.Time.Add(-window))
+}
+
+// delivered records receipt in the dedup store, once it has been delivered
+func (rc *ReceiptHandler) delivered(receipt Receipt) error {
+	if rc.Dedup == nil {
+		return nil
+	}
+	return rc.Dedup.Add(dedupKey(receipt), receipt.Time)
+}
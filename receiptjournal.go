@@ -0,0 +1,158 @@
+package clockwork
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// ReceiptJournal append-only file of the receipts a ReceiptHandler has
+// received. Each receipt is written and synced to disk before it is
+// acknowledged, so a receipt whose callback crashed can be recovered with
+// ReplayReceiptJournal. It is safe for concurrent use.
+type ReceiptJournal struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// journalEntry a receipt as stored in the journal. The raw values are kept
+// rather than the parsed Receipt, so replay parses them exactly as ServeHTTP
+// would.
+type journalEntry struct {
+	Time   time.Time  `json:"time"`
+	Values url.Values `json:"values"`
+}
+
+// OpenReceiptJournal opens the journal at path for appending, creating it if
+// it does not exist. A partly written last line, left by a crash mid append,
+// is removed so new receipts do not join onto it.
+func OpenReceiptJournal(path string) (*ReceiptJournal, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := trimPartialLine(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &ReceiptJournal{f: f}, nil
+}
+
+// trimPartialLine truncates f after its last newline
+func trimPartialLine(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	end := info.Size()
+	buf := make([]byte, 4096)
+	for off := end; off > 0; {
+		n := int64(len(buf))
+		if n > off {
+			n = off
+		}
+		off -= n
+		if _, err := f.ReadAt(buf[:n], off); err != nil {
+			return err
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			return truncate(f, off+int64(i)+1, end)
+		}
+	}
+	return truncate(f, 0, end)
+}
+
+// truncate shortens f from size end to size, syncing the change to disk
+func truncate(f *os.File, size, end int64) error {
+	if size == end {
+		return nil
+	}
+	if err := f.Truncate(size); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// append writes the receipt values received at t to the journal and syncs
+// it. The secret token is never written.
+func (j *ReceiptJournal) append(vals url.Values, t time.Time) error {
+	stored := make(url.Values, len(vals))
+	for k, v := range vals {
+		if k != "token" {
+			stored[k] = v
+		}
+	}
+	line, err := json.Marshal(journalEntry{Time: t, Values: stored})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.f.Write(line); err != nil {
+		return err
+	}
+	return j.f.Sync()
+}
+
+// Truncate empties the journal, for example once it has been replayed
+func (j *ReceiptJournal) Truncate() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.f.Truncate(0); err != nil {
+		return err
+	}
+	return j.f.Sync()
+}
+
+// Close closes the journal file
+func (j *ReceiptJournal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.f.Close()
+}
+
+// ReplayReceiptJournal calls callback with each receipt in the journal at
+// path, oldest first, and returns the number replayed. A missing journal
+// replays nothing. A partly written last line, left by a crash mid append, is
+// skipped; a corrupt line anywhere else is an error.
+func ReplayReceiptJournal(path string, callback ReceiptCallback) (int, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var n int
+	r := bufio.NewReader(f)
+	for lineNo := 1; ; lineNo++ {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// an unterminated line was never synced in full
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var e journalEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return n, fmt.Errorf("clockwork: receipt journal %s line %d: %v", path, lineNo, err)
+		}
+		callback(parseReceipt(e.Values, e.Time))
+		n++
+	}
+}
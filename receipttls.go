@@ -0,0 +1,91 @@
+package clockwork
+
+import (
+	"crypto/tls"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// tlsConfig returns the TLS configuration to serve receipts with, nil to
+// serve plain HTTP
+func (rc *ReceiptHandler) tlsConfig() (*tls.Config, error) {
+	if rc.CertFile == "" && rc.KeyFile == "" {
+		return rc.TLSConfig, nil
+	}
+	if rc.CertFile == "" || rc.KeyFile == "" {
+		return nil, errors.New("clockwork: both CertFile and KeyFile must be set")
+	}
+	reloader := &certReloader{certFile: rc.CertFile, keyFile: rc.KeyFile}
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if rc.TLSConfig != nil {
+		cfg = rc.TLSConfig.Clone()
+	}
+	cfg.Certificates = nil
+	cfg.GetCertificate = reloader.getCertificate
+	return cfg, nil
+}
+
+// certReloader serves a certificate from disk, reloading it when the files
+// change
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+}
+
+// getCertificate returns the current certificate, reloading it first if the
+// files have changed since they were loaded. The files are only stat'ed on
+// each handshake, so this is cheap. If a reload fails, e.g. because a rotation
+// is half written, the previous certificate is kept and the reload is tried
+// again on the next handshake.
+func (c *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.changed() {
+		c.loadLocked()
+	}
+	return c.cert, nil
+}
+
+// load reads the certificate and key files
+func (c *certReloader) load() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.loadLocked()
+}
+
+// loadLocked reads the certificate and key files. c.mu must be held.
+func (c *certReloader) loadLocked() error {
+	certMod, keyMod := modTime(c.certFile), modTime(c.keyFile)
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+	c.cert, c.certMod, c.keyMod = &cert, certMod, keyMod
+	return nil
+}
+
+// changed reports whether either file has been modified since it was loaded.
+// c.mu must be held.
+func (c *certReloader) changed() bool {
+	return !modTime(c.certFile).Equal(c.certMod) || !modTime(c.keyFile).Equal(c.keyMod)
+}
+
+// modTime returns when the file at path was last modified, the zero time if
+// it cannot be read
+func modTime(path string) time.Time {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
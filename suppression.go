@@ -0,0 +1,217 @@
+package clockwork
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SuppressionList holds numbers which must never be sent an SMS, for example
+// recipients who have replied STOP. Numbers are compared after stripping
+// formatting, so "+44 1234 567890" and "441234567890" are the same number.
+type SuppressionList interface {
+	// Suppressed reports whether number is on the list
+	Suppressed(number string) (bool, error)
+	// Add puts number on the list
+	Add(number string) error
+	// Remove takes number off the list
+	Remove(number string) error
+}
+
+// MemorySuppressionList suppression list held in memory. It is safe for
+// concurrent use.
+type MemorySuppressionList struct {
+	mu      sync.RWMutex
+	numbers map[string]bool
+}
+
+// NewMemorySuppressionList creates a suppression list holding numbers
+func NewMemorySuppressionList(numbers ...string) *MemorySuppressionList {
+	l := &MemorySuppressionList{numbers: make(map[string]bool)}
+	for _, n := range numbers {
+		l.numbers[normaliseNumber(n)] = true
+	}
+	return l
+}
+
+// Suppressed reports whether number is on the list
+func (l *MemorySuppressionList) Suppressed(number string) (bool, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.numbers[normaliseNumber(number)], nil
+}
+
+// Add puts number on the list
+func (l *MemorySuppressionList) Add(number string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.numbers[normaliseNumber(number)] = true
+	return nil
+}
+
+// Remove takes number off the list
+func (l *MemorySuppressionList) Remove(number string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.numbers, normaliseNumber(number))
+	return nil
+}
+
+// Numbers returns the numbers on the list in sorted order
+func (l *MemorySuppressionList) Numbers() Numbers {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	nums := make(Numbers, 0, len(l.numbers))
+	for n := range l.numbers {
+		nums = append(nums, n)
+	}
+	sort.Strings(nums)
+	return nums
+}
+
+// FileSuppressionList suppression list persisted to a plain text file, one
+// number per line. Blank lines and lines starting with '#' are ignored. It is
+// safe for concurrent use within a single process.
+type FileSuppressionList struct {
+	mu   sync.Mutex
+	path string
+	mem  *MemorySuppressionList
+}
+
+// NewFileSuppressionList loads the suppression list stored at path. The file
+// is created when the first number is added if it does not already exist.
+func NewFileSuppressionList(path string) (*FileSuppressionList, error) {
+	l := &FileSuppressionList{
+		path: path,
+		mem:  NewMemorySuppressionList(),
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		l.mem.Add(line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("clockwork: reading suppression list %s: %v", path, err)
+	}
+	return l, nil
+}
+
+// Suppressed reports whether number is on the list
+func (l *FileSuppressionList) Suppressed(number string) (bool, error) {
+	return l.mem.Suppressed(number)
+}
+
+// Add puts number on the list and appends it to the file
+func (l *FileSuppressionList) Add(number string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if ok, _ := l.mem.Suppressed(number); ok {
+		return nil
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, normaliseNumber(number)); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return l.mem.Add(number)
+}
+
+// Remove takes number off the list and rewrites the file without the lines
+// holding it. Comments and every other line are kept as written. The number
+// stays on the list if the file cannot be rewritten.
+func (l *FileSuppressionList) Remove(number string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if ok, _ := l.mem.Suppressed(number); !ok {
+		return nil
+	}
+	remove := normaliseNumber(number)
+
+	data, err := ioutil.ReadFile(l.path)
+	if os.IsNotExist(err) {
+		return l.mem.Remove(number)
+	}
+	if err != nil {
+		return err
+	}
+	var kept []string
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		n := strings.TrimSpace(line)
+		if n != "" && !strings.HasPrefix(n, "#") && normaliseNumber(n) == remove {
+			continue
+		}
+		kept = append(kept, line)
+	}
+
+	// write to a temporary file first so a crash mid write cannot truncate
+	// the list
+	tmp, err := ioutil.TempFile(filepath.Dir(l.path), filepath.Base(l.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.WriteString(strings.Join(kept, "")); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return l.mem.Remove(number)
+}
+
+// Numbers returns the numbers on the list in sorted order
+func (l *FileSuppressionList) Numbers() Numbers {
+	return l.mem.Numbers()
+}
+
+// suppress removes numbers on list from the sms recipients. It returns the
+// filtered sms and the numbers which were removed. The sms passed in is not
+// modified.
+func suppress(list SuppressionList, sms SMS) (SMS, Numbers, error) {
+	if list == nil {
+		return sms, nil, nil
+	}
+	var to, suppressed Numbers
+	for _, n := range sms.To {
+		ok, err := list.Suppressed(n)
+		if err != nil {
+			return sms, nil, err
+		}
+		if ok {
+			suppressed = append(suppressed, n)
+			continue
+		}
+		to = append(to, n)
+	}
+	sms.To = to
+	return sms, suppressed, nil
+}
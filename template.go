@@ -0,0 +1,146 @@
+package clockwork
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Recipient a number and the data used to personalise a message for it
+type Recipient struct {
+	// Number to send to
+	Number string
+	// Data passed to the template when rendering content for this number
+	Data map[string]interface{}
+}
+
+// Template renders message content per recipient using text/template, so the
+// same message can be personalised for many people:
+//
+//	t, err := clockwork.NewTemplate(clockwork.SMS{From: "Gopher"},
+//	    "Hi {{.name}}, your code is {{.code}}")
+//	// ...
+//	msgs, err := t.Render([]clockwork.Recipient{
+//	    {Number: "441234567890", Data: map[string]interface{}{"name": "Ann", "code": 42}},
+//	    {Number: "447700900123", Data: map[string]interface{}{"name": "Bob", "code": 7}},
+//	})
+//
+// Recipients whose rendered content is identical share an SMS.
+type Template struct {
+	// SMS the message options every rendered message starts from. Its To and
+	// Content are replaced. Its ClientID and IdempotencyKey are cleared, as
+	// messages with different content must not share them.
+	SMS  SMS
+	tmpl *template.Template
+}
+
+// NewTemplate parses text as the content template. Referring to a key which
+// is missing from a recipient's data is an error.
+func NewTemplate(base SMS, text string) (*Template, error) {
+	tmpl, err := template.New("content").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{SMS: base, tmpl: tmpl}, nil
+}
+
+// RenderError a message could not be rendered for a recipient
+type RenderError struct {
+	// Number of the recipient
+	Number string
+	// Err why rendering failed, ErrMissingContent, ErrInvalidCharInContent,
+	// ErrMessageTooLong or a text/template execution error
+	Err error
+}
+
+// Error returns the render error as a string
+func (e *RenderError) Error() string {
+	return fmt.Sprintf("clockwork: rendering message for %s: %v", e.Number, e.Err)
+}
+
+// RenderErrors every recipient a message could not be rendered for
+type RenderErrors []*RenderError
+
+// Error returns the render errors as a string
+func (e RenderErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Render renders and validates the content for each recipient, then groups
+// recipients with identical content into SMS values of up to 50 numbers, in
+// the order the recipients were given. Content is validated against the
+// template's SMS options: TEXT messages must only use the GSM character set
+// (unless InvalidCharAction removes or replaces other characters) and fit in
+// Concat parts (unless Truncate is ReplaceExtraText).
+//
+// If some recipients fail, the messages for the rest are returned along with
+// a RenderErrors.
+func (t *Template) Render(recipients []Recipient) ([]SMS, error) {
+	var msgs []SMS
+	var errs RenderErrors
+	// index of the latest message for each distinct content
+	latest := make(map[string]int)
+	for _, r := range recipients {
+		content, err := t.render(r)
+		if err != nil {
+			errs = append(errs, &RenderError{Number: r.Number, Err: err})
+			continue
+		}
+		i, ok := latest[content]
+		if !ok || len(msgs[i].To) >= maxRecipients {
+			sms := t.SMS
+			sms.To = nil
+			sms.Content = content
+			sms.ClientID, sms.IdempotencyKey = "", ""
+			msgs = append(msgs, sms)
+			i = len(msgs) - 1
+			latest[content] = i
+		}
+		msgs[i].To = append(msgs[i].To, r.Number)
+	}
+	if len(errs) > 0 {
+		return msgs, errs
+	}
+	return msgs, nil
+}
+
+// render renders and validates the content for a single recipient
+func (t *Template) render(r Recipient) (string, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, r.Data); err != nil {
+		return "", err
+	}
+	sms := t.SMS
+	sms.Content = buf.String()
+	if err := validateContent(sms); err != nil {
+		return "", err
+	}
+	return sms.Content, nil
+}
+
+// validateContent checks the content of sms can be sent as is, returning the
+// error the API would
+func validateContent(sms SMS) error {
+	if sms.Content == "" {
+		return ErrMissingContent
+	}
+	if sms.MsgType != UCS2 {
+		replaced := sms.InvalidCharAction == RemoveInvalidChars || sms.InvalidCharAction == ReplaceInvalidChars
+		if !replaced && !isGSM(sms.Content) {
+			return ErrInvalidCharInContent
+		}
+	}
+	concat := sms.Concat
+	if concat < OnePart {
+		concat = OnePart
+	}
+	if sms.Parts() > concat {
+		return ErrMessageTooLong
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package clockwork
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TrackedMessage a message followed by a Tracker
+type TrackedMessage struct {
+	// ID message ID returned by Send
+	ID string
+	// To number the message was sent to
+	To string
+	// ClientID the message was sent with, if any
+	ClientID string
+	// Sent when the message was tracked, zero if a receipt arrived first
+	Sent time.Time
+	// State current delivery state, Queued until a receipt arrives
+	State DeliveryState
+	// Err error from the latest receipt, if any
+	Err error
+	// History the receipts which moved the message to a new state, oldest
+	// first
+	History []Receipt
+	// Updated when the message was last tracked or changed state
+	Updated time.Time
+}
+
+// Tracker follows each message returned by Send through its delivery
+// receipts. Feed it Send results with Track and receipts with Update, or use
+// Callback as a ReceiptHandler callback. It is safe for concurrent use.
+type Tracker struct {
+	mu       sync.RWMutex
+	messages map[string]*TrackedMessage
+	byClient map[string][]string
+	byNumber map[string][]string
+}
+
+// NewTracker creates an empty tracker
+func NewTracker() *Tracker {
+	return &Tracker{
+		messages: make(map[string]*TrackedMessage),
+		byClient: make(map[string][]string),
+		byNumber: make(map[string][]string),
+	}
+}
+
+// Track records each message ID in resp with its number and ClientID.
+// Numbers without an ID, such as suppressed or blocked ones, are skipped.
+func (t *Tracker) Track(resp SMSResponse) {
+	now := time.Now().UTC()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for number, fields := range resp {
+		if fields["ID"] == "" {
+			continue
+		}
+		m := t.message(fields["ID"], number, fields["ClientID"], now)
+		m.Sent = now
+	}
+}
+
+// Update applies receipt to its message. A receipt for a message not yet
+// tracked starts tracking it, as receipts can arrive before Send returns. A
+// repeated receipt for the current state is ignored. A receipt which would
+// move the message back to an earlier state, or out of a final state, is
+// ignored and returns ErrInvalidTransition.
+func (t *Tracker) Update(receipt Receipt) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	m, ok := t.messages[receipt.ID]
+	if !ok {
+		m = t.message(receipt.ID, receipt.To, receipt.ClientID, receipt.Time)
+		m.State = receipt.Status
+		m.Err = receipt.Err
+		m.History = append(m.History, receipt)
+		return nil
+	}
+	t.fill(m, receipt.To, receipt.ClientID)
+	if len(m.History) > 0 && receipt.Status == m.State {
+		return nil
+	}
+	if !validTransition(m.State, receipt.Status) {
+		return ErrInvalidTransition
+	}
+	m.State = receipt.Status
+	m.Err = receipt.Err
+	m.History = append(m.History, receipt)
+	m.Updated = receipt.Time
+	return nil
+}
+
+// Callback applies receipt with Update, logging any error. It can be used as
+// a ReceiptHandler callback.
+func (t *Tracker) Callback(receipt Receipt) {
+	if err := t.Update(receipt); err != nil {
+		log.Println("tracker:", receipt.ID, receipt.RawStatus, err)
+	}
+}
+
+// Get returns the message with id
+func (t *Tracker) Get(id string) (TrackedMessage, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	m, ok := t.messages[id]
+	if !ok {
+		return TrackedMessage{}, false
+	}
+	return m.copy(), true
+}
+
+// ByClientID returns the messages sent with clientID, oldest first
+func (t *Tracker) ByClientID(clientID string) []TrackedMessage {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.lookup(t.byClient[clientID])
+}
+
+// ByNumber returns the messages sent to number, oldest first
+func (t *Tracker) ByNumber(number string) []TrackedMessage {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.lookup(t.byNumber[normaliseNumber(number)])
+}
+
+// Prune stops tracking messages in a final state which have not changed since
+// before, and returns the number removed
+func (t *Tracker) Prune(before time.Time) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var n int
+	for id, m := range t.messages {
+		if m.State.IsFinal() && m.Updated.Before(before) {
+			delete(t.messages, id)
+			n++
+		}
+	}
+	if n > 0 {
+		t.byClient = t.reindex(t.byClient)
+		t.byNumber = t.reindex(t.byNumber)
+	}
+	return n
+}
+
+// message returns the message with id, creating it if it is not tracked. A
+// number or ClientID the message does not have yet is filled in and indexed.
+func (t *Tracker) message(id, to, clientID string, now time.Time) *TrackedMessage {
+	m, ok := t.messages[id]
+	if !ok {
+		m = &TrackedMessage{ID: id, State: Queued, Updated: now}
+		t.messages[id] = m
+	}
+	t.fill(m, to, clientID)
+	return m
+}
+
+// fill sets the number and ClientID of m if they are empty, and indexes them
+func (t *Tracker) fill(m *TrackedMessage, to, clientID string) {
+	if m.ClientID == "" && clientID != "" {
+		m.ClientID = clientID
+		t.byClient[clientID] = append(t.byClient[clientID], m.ID)
+	}
+	if m.To == "" && to != "" {
+		m.To = to
+		n := normaliseNumber(to)
+		t.byNumber[n] = append(t.byNumber[n], m.ID)
+	}
+}
+
+// lookup returns copies of the messages with ids, oldest first
+func (t *Tracker) lookup(ids []string) []TrackedMessage {
+	var msgs []TrackedMessage
+	for _, id := range ids {
+		if m, ok := t.messages[id]; ok {
+			msgs = append(msgs, m.copy())
+		}
+	}
+	sort.SliceStable(msgs, func(i, j int) bool {
+		return msgs[i].first().Before(msgs[j].first())
+	})
+	return msgs
+}
+
+// reindex returns index without the ids of messages no longer tracked
+func (t *Tracker) reindex(index map[string][]string) map[string][]string {
+	out := make(map[string][]string, len(index))
+	for k, ids := range index {
+		var keep []string
+		for _, id := range ids {
+			if _, ok := t.messages[id]; ok {
+				keep = append(keep, id)
+			}
+		}
+		if len(keep) > 0 {
+			out[k] = keep
+		}
+	}
+	return out
+}
+
+// copy returns a copy of m which shares no history with it
+func (m *TrackedMessage) copy() TrackedMessage {
+	c := *m
+	c.History = append([]Receipt(nil), m.History...)
+	return c
+}
+
+// first returns when the message was first seen
+func (m *TrackedMessage) first() time.Time {
+	if !m.Sent.IsZero() {
+		return m.Sent
+	}
+	if len(m.History) > 0 {
+		return m.History[0].Time
+	}
+	return m.Updated
+}
+
+// validTransition reports whether a message may move from one delivery state
+// to another. States only move forward, Queued then Enroute then a final
+// state, and never leave a final state. Unrecognised states rank alongside
+// Enroute.
+func validTransition(from, to DeliveryState) bool {
+	return !from.IsFinal() && stateRank(to) >= stateRank(from)
+}
+
+// stateRank orders delivery states for validTransition
+func stateRank(d DeliveryState) int {
+	switch {
+	case d == Queued:
+		return 0
+	case d.IsFinal():
+		return 2
+	default:
+		return 1
+	}
+}